@@ -0,0 +1,309 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Port forwarding: the `ssh -L` a user would otherwise write by hand to open a port that a container on a remote
+// engine publishes. Each forward is a TCP listener on 127.0.0.1 (a chosen port, or a free one). Every accepted
+// connection is tunnelled through the bridge's existing SSH link to host:port on the remote:
+//   - kind "ssh": a direct-tcpip channel on the in-process client.
+//   - kinds "stdio"/"tunnel" over the ssh binary: a per-connection `ssh -W host:port` child, which rides the
+//     ControlMaster when there is one.
+// WSL bridges have no SSH link, so they cannot forward. Forwards belong to their bridge and are closed when it stops.
+
+// PortForwardRequest asks for a forward on bridge Key. LocalPort 0 picks a free port; RemoteHost defaults to
+// localhost (the remote machine itself, where published container ports listen).
+type PortForwardRequest struct {
+	Key        string `json:"key"`
+	LocalPort  int    `json:"localPort"`
+	RemoteHost string `json:"remoteHost"`
+	RemotePort int    `json:"remotePort"`
+}
+
+// PortForward is one live forward, with the same traffic counters as BridgeInfo.
+type PortForward struct {
+	ID                string `json:"id"`
+	Key               string `json:"key"`
+	LocalAddress      string `json:"localAddress"`
+	LocalPort         int    `json:"localPort"`
+	RemoteHost        string `json:"remoteHost"`
+	RemotePort        int    `json:"remotePort"`
+	CreatedAt         string `json:"createdAt"`
+	ActiveConnections int64  `json:"activeConnections"`
+	TotalConnections  uint64 `json:"totalConnections"`
+	BytesIn           uint64 `json:"bytesIn"`
+	BytesOut          uint64 `json:"bytesOut"`
+	LastError         string `json:"lastError,omitempty"`
+}
+
+type portForwardIDArgs struct {
+	ID string `json:"id"`
+}
+
+type portForward struct {
+	id       string
+	key      string
+	entry    *bridgeEntry
+	listener net.Listener
+	remote   string
+	// argv is the authorized `ssh … -W remote` each connection runs; nil over an in-process "ssh" bridge.
+	argv    []string
+	request PortForwardRequest
+	created time.Time
+	metrics bridgeMetrics
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// CreatePortForward opens a local listener tunnelled to RemoteHost:RemotePort through bridge Key. The bridge must be
+// up (ensure runs on the first proxied request).
+func (s *BridgeService) CreatePortForward(req PortForwardRequest) (PortForward, error) {
+	return bridges.createForward(req)
+}
+
+// ListPortForwards reports the live forwards of bridge Key (every bridge's when Key is empty), sorted by local port.
+func (s *BridgeService) ListPortForwards(args bridgeKeyArgs) []PortForward {
+	return bridges.listForwards(args.Key)
+}
+
+// StopPortForward closes one forward and its open connections.
+func (s *BridgeService) StopPortForward(args portForwardIDArgs) error {
+	return bridges.stopForward(args.ID)
+}
+
+func (m *bridgeManager) createForward(req PortForwardRequest) (PortForward, error) {
+	if req.RemotePort <= 0 || req.RemotePort > 65535 {
+		return PortForward{}, fmt.Errorf("invalid remote port %d", req.RemotePort)
+	}
+	if req.LocalPort < 0 || req.LocalPort > 65535 {
+		return PortForward{}, fmt.Errorf("invalid local port %d", req.LocalPort)
+	}
+	if req.RemoteHost == "" {
+		req.RemoteHost = "localhost"
+	}
+	m.mu.Lock()
+	entry, ok := m.entries[req.Key]
+	m.mu.Unlock()
+	if !ok {
+		return PortForward{}, errors.New("no bridge with key " + req.Key)
+	}
+	if entry.ssh == nil && !isSSHLauncher(entry.spec.Launcher) {
+		return PortForward{}, fmt.Errorf("bridge %s has no SSH link to forward through", req.Key)
+	}
+	remote := net.JoinHostPort(req.RemoteHost, strconv.Itoa(req.RemotePort))
+	var argv []string
+	if entry.ssh == nil {
+		// The child every connection spawns is authorized once, here, like a bridge's launcher at ensure.
+		var err error
+		if argv, err = sshStdioForwardArgv(entry, remote); err != nil {
+			return PortForward{}, err
+		}
+		if err := launchPolicy.authorize(commandRequest{"forward", entry.spec.Launcher, argv, "", ""}); err != nil {
+			return PortForward{}, err
+		}
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(req.LocalPort)))
+	if err != nil {
+		return PortForward{}, err
+	}
+	req.LocalPort = listener.Addr().(*net.TCPAddr).Port
+	forward := &portForward{
+		id:       fmt.Sprintf("forward-%d", m.forwardCounter.Add(1)),
+		key:      req.Key,
+		entry:    entry,
+		listener: listener,
+		remote:   remote,
+		argv:     argv,
+		request:  req,
+		created:  time.Now(),
+		conns:    map[net.Conn]struct{}{},
+	}
+	m.mu.Lock()
+	if m.entries[req.Key] != entry {
+		// The bridge stopped while the listener was being bound.
+		m.mu.Unlock()
+		_ = listener.Close()
+		return PortForward{}, errors.New("bridge " + req.Key + " stopped")
+	}
+	if m.forwards == nil {
+		m.forwards = map[string]*portForward{}
+	}
+	m.forwards[forward.id] = forward
+	m.mu.Unlock()
+	go forward.serve()
+	return forward.info(), nil
+}
+
+func (m *bridgeManager) listForwards(key string) []PortForward {
+	m.mu.Lock()
+	out := []PortForward{}
+	for _, forward := range m.forwards {
+		if key == "" || forward.key == key {
+			out = append(out, forward.info())
+		}
+	}
+	m.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].LocalPort < out[j].LocalPort })
+	return out
+}
+
+func (m *bridgeManager) stopForward(id string) error {
+	m.mu.Lock()
+	forward, ok := m.forwards[id]
+	delete(m.forwards, id)
+	m.mu.Unlock()
+	if !ok {
+		return errors.New("no port forward with id " + id)
+	}
+	forward.close()
+	return nil
+}
+
+// closeForwards closes every forward of a bridge that is going away (stop, or a tunnel the supervisor gave up on).
+func (m *bridgeManager) closeForwards(key string) {
+	m.mu.Lock()
+	closing := []*portForward{}
+	for id, forward := range m.forwards {
+		if forward.key == key {
+			closing = append(closing, forward)
+			delete(m.forwards, id)
+		}
+	}
+	m.mu.Unlock()
+	for _, forward := range closing {
+		forward.close()
+	}
+}
+
+func (f *portForward) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		metered := f.metrics.meter(conn)
+		f.mu.Lock()
+		f.conns[metered] = struct{}{}
+		f.mu.Unlock()
+		go f.relay(metered)
+	}
+}
+
+// close stops accepting and cuts the connections still open (their relays then wind down).
+func (f *portForward) close() {
+	_ = f.listener.Close()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for conn := range f.conns {
+		_ = conn.Close()
+	}
+}
+
+// relay tunnels one local TCP connection to the remote address over the bridge's SSH link.
+func (f *portForward) relay(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+		f.mu.Lock()
+		delete(f.conns, conn)
+		f.mu.Unlock()
+	}()
+	if transport := f.entry.ssh; transport != nil {
+		client, err := transport.get()
+		if err != nil {
+			f.metrics.fail(err)
+			return
+		}
+		remote, err := client.Dial("tcp", f.remote)
+		if err != nil {
+			f.metrics.fail(err)
+			return
+		}
+		defer func() { _ = remote.Close() }()
+		shuttle(conn, remote, func() { closeWrite(remote) }, remote)
+		return
+	}
+	child, err := spawnStdioChild(f.entry.spec.Launcher, f.argv, f.entry.stderr)
+	if err != nil {
+		f.metrics.fail(err)
+		return
+	}
+	shuttle(conn, child.stdin, func() { _ = child.stdin.Close() }, child.stdout)
+	child.kill()
+}
+
+func (f *portForward) info() PortForward {
+	return PortForward{
+		ID:                f.id,
+		Key:               f.key,
+		LocalAddress:      f.listener.Addr().String(),
+		LocalPort:         f.request.LocalPort,
+		RemoteHost:        f.request.RemoteHost,
+		RemotePort:        f.request.RemotePort,
+		CreatedAt:         f.created.Format(time.RFC3339),
+		ActiveConnections: f.metrics.active.Load(),
+		TotalConnections:  f.metrics.total.Load(),
+		BytesIn:           f.metrics.bytesIn.Load(),
+		BytesOut:          f.metrics.bytesOut.Load(),
+		LastError:         f.metrics.lastErr(),
+	}
+}
+
+// sshStdioForwardArgv turns the bridge's ssh argv into `ssh <options> -W remote <target>`. It keeps the
+// connection options (port, identity, BatchMode…), drops the bridge's own forwards and -N, and rides the
+// ControlMaster when the bridge has one.
+func sshStdioForwardArgv(entry *bridgeEntry, remote string) ([]string, error) {
+	options, target, _, ok := splitSSHArgv(entry.spec.Argv)
+	if !ok {
+		return nil, errors.New("cannot find the ssh destination in the bridge argv")
+	}
+	argv := stripSSHForwarding(options)
+	if master := entry.master; master != nil {
+		argv = append(argv, "-oControlMaster=no", "-oControlPath="+master.controlPath)
+	}
+	return append(argv, "-W", remote, target), nil
+}
+
+// stripSSHForwarding removes -L/-R/-D/-W (with their values) and the -N/-f flags from ssh options, splitting
+// clusters like -NL apart. A valued option that is kept is normalized to its attached form (-p22).
+func stripSSHForwarding(options []string) []string {
+	out := []string{}
+	for index := 0; index < len(options); index++ {
+		arg := options[index]
+		flags := ""
+		for position := 1; position < len(arg); position++ {
+			letter := arg[position]
+			if strings.IndexByte(sshOptionsWithArgument, letter) < 0 {
+				if letter != 'N' && letter != 'f' {
+					flags += string(letter)
+				}
+				continue
+			}
+			value := arg[position+1:]
+			if value == "" && index+1 < len(options) {
+				index++
+				value = options[index]
+			}
+			if flags != "" {
+				out = append(out, "-"+flags)
+				flags = ""
+			}
+			if strings.IndexByte("LRDW", letter) < 0 {
+				out = append(out, "-"+string(letter)+value)
+			}
+			break
+		}
+		if flags != "" {
+			out = append(out, "-"+flags)
+		}
+	}
+	return out
+}
@@ -0,0 +1,132 @@
+//go:build !windows
+
+package main
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// startUppercaseServer is the "published container port": a TCP server answering each connection with its input
+// uppercased.
+func startUppercaseServer(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				data, _ := io.ReadAll(conn)
+				_, _ = conn.Write([]byte(strings.ToUpper(string(data))))
+			}()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func tcpRoundTrip(t *testing.T, address, message string) string {
+	t.Helper()
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("dial forward: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	_, _ = conn.Write([]byte(message))
+	_ = conn.(*net.TCPConn).CloseWrite()
+	reply, _ := io.ReadAll(conn)
+	return string(reply)
+}
+
+// Over an in-process SSH bridge, a forward opens a direct-tcpip channel per connection; stopping the bridge closes
+// the forward with it.
+func TestPortForwardOverSSHBridge(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	server := startTestSSHServer(t)
+	remotePort := startUppercaseServer(t)
+	sshSpec := server.spec(t, server.hostKey)
+	sshSpec.Command = "docker system dial-stdio"
+	manager := &bridgeManager{}
+	socket := filepath.Join(t.TempDir(), "ssh-bridge.sock")
+	if _, err := manager.ensure(bridgeSpec{Kind: "ssh", Key: "remote", LocalAddress: socket, SSH: &sshSpec}); err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+
+	forward, err := manager.createForward(PortForwardRequest{Key: "remote", RemoteHost: "127.0.0.1", RemotePort: remotePort})
+	if err != nil {
+		t.Fatalf("create forward: %v", err)
+	}
+	if forward.LocalPort == 0 || forward.RemoteHost != "127.0.0.1" {
+		t.Fatalf("forward = %+v", forward)
+	}
+	if got := tcpRoundTrip(t, forward.LocalAddress, "published"); got != "PUBLISHED" {
+		t.Fatalf("reply = %q, want PUBLISHED", got)
+	}
+	if listed := manager.listForwards("remote"); len(listed) != 1 || listed[0].TotalConnections != 1 {
+		t.Fatalf("list = %+v", listed)
+	}
+
+	manager.stop("remote")
+	if listed := manager.listForwards(""); len(listed) != 0 {
+		t.Fatalf("forwards left after the bridge stopped: %+v", listed)
+	}
+	if conn, err := net.Dial("tcp", forward.LocalAddress); err == nil {
+		_ = conn.Close()
+		t.Fatal("forward listener still accepting after the bridge stopped")
+	}
+}
+
+// Over the ssh binary, each connection runs `ssh <options> -W host:port <target>`, with the bridge's own -NL
+// forward stripped. The fake ssh here just runs cat, so the reply is the request echoed back.
+func TestPortForwardOverSSHBinary(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "ssh.log")
+	t.Setenv("FAKE_SSH_LOG", log)
+	launcher := filepath.Join(t.TempDir(), "ssh")
+	if err := os.WriteFile(launcher, []byte(fakeSSHScript), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	manager := &bridgeManager{}
+	entry := &bridgeEntry{spec: bridgeSpec{
+		Kind: "tunnel", Key: "tunnel", Launcher: launcher,
+		Argv: []string{"-oBatchMode=yes", "-p", "2222", "-NL", "/local.sock:/remote.sock", "me@remote"},
+	}, stderr: newBridgeStderr("tunnel")}
+	manager.entries = map[string]*bridgeEntry{"tunnel": entry}
+
+	forward, err := manager.createForward(PortForwardRequest{Key: "tunnel", RemotePort: 8080})
+	if err != nil {
+		t.Fatalf("create forward: %v", err)
+	}
+	if got := tcpRoundTrip(t, forward.LocalAddress, "echo"); got != "echo" {
+		t.Fatalf("reply = %q", got)
+	}
+	raw, _ := os.ReadFile(log)
+	if got := strings.TrimSpace(string(raw)); got != "-oBatchMode=yes -p2222 -W localhost:8080 me@remote" {
+		t.Fatalf("ssh argv = %q", got)
+	}
+	if err := manager.stopForward(forward.ID); err != nil {
+		t.Fatalf("stop forward: %v", err)
+	}
+	if err := manager.stopForward(forward.ID); err == nil {
+		t.Fatal("stopping a stopped forward succeeded")
+	}
+}
+
+func TestStripSSHForwarding(t *testing.T) {
+	got := stripSSHForwarding([]string{"-NL", "/a:/b", "-fTi", "key", "-R8080:x:80", "-v", "-D", "1080", "-oX=y"})
+	if want := []string{"-T", "-ikey", "-v", "-oX=y"}; !slices.Equal(got, want) {
+		t.Fatalf("stripped = %v, want %v", got, want)
+	}
+}
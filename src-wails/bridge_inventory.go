@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Bridge inventory — BridgeService.List reports every live bridge with its traffic, and Restart bounces one. The
+// stdio and ssh kinds are metered where they accept connections (one connection = one dial-stdio child or SSH
+// session); a tunnel has no accept loop of ours, so ProxyService meters the connections it dials to the tunnel's
+// forwarded socket instead (meterDial).
+
+// BridgeInfo is one List row. BytesOut counts app → engine, BytesIn engine → app. Argv is redacted (redactArgs);
+// Target is the in-process SSH destination for kind "ssh" (which has no launcher/argv).
+type BridgeInfo struct {
+	Key               string   `json:"key"`
+	Kind              string   `json:"kind"`
+	LocalAddress      string   `json:"localAddress"`
+	Launcher          string   `json:"launcher,omitempty"`
+	Argv              []string `json:"argv"`
+	Target            string   `json:"target,omitempty"`
+	StartedAt         string   `json:"startedAt"`
+	UptimeMs          int64    `json:"uptimeMs"`
+	ActiveConnections int64    `json:"activeConnections"`
+	TotalConnections  uint64   `json:"totalConnections"`
+	BytesIn           uint64   `json:"bytesIn"`
+	BytesOut          uint64   `json:"bytesOut"`
+	PooledChildren    int      `json:"pooledChildren"`
+	LastError         string   `json:"lastError,omitempty"`
+}
+
+// bridgeMetrics is embedded by value in bridgeEntry; the counters are atomics so the per-connection goroutines
+// update them without the manager lock.
+type bridgeMetrics struct {
+	active   atomic.Int64
+	total    atomic.Uint64
+	bytesIn  atomic.Uint64
+	bytesOut atomic.Uint64
+
+	mu        sync.Mutex
+	lastError string
+}
+
+func (m *bridgeMetrics) fail(err error) {
+	m.mu.Lock()
+	m.lastError = err.Error()
+	m.mu.Unlock()
+}
+
+func (m *bridgeMetrics) lastErr() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastError
+}
+
+// meter counts a new connection and wraps it so its traffic is counted; Close ends it (once).
+func (m *bridgeMetrics) meter(conn net.Conn) net.Conn {
+	m.total.Add(1)
+	m.active.Add(1)
+	return &meteredConn{Conn: conn, metrics: m}
+}
+
+// meteredConn counts bytes through a bridge's local connection. Reads are what the app sends (→ engine), writes
+// what it receives. CloseWrite is forwarded so the half-close in shuttle still reaches the real socket.
+type meteredConn struct {
+	net.Conn
+	metrics *bridgeMetrics
+	closed  atomic.Bool
+}
+
+func (c *meteredConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.metrics.bytesOut.Add(uint64(n))
+	return n, err
+}
+
+func (c *meteredConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.metrics.bytesIn.Add(uint64(n))
+	return n, err
+}
+
+func (c *meteredConn) CloseWrite() error {
+	if halfCloser, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return halfCloser.CloseWrite()
+	}
+	return nil
+}
+
+func (c *meteredConn) Close() error {
+	if c.closed.CompareAndSwap(false, true) {
+		c.metrics.active.Add(-1)
+	}
+	return c.Conn.Close()
+}
+
+// List reports every live bridge, sorted by key.
+func (s *BridgeService) List() []BridgeInfo {
+	return bridges.list()
+}
+
+// Restart stops a bridge and brings it back up from the same spec (a fresh listener / tunnel / SSH connection).
+// Like Stop, it closes the bridge's port forwards.
+func (s *BridgeService) Restart(args bridgeKeyArgs) error {
+	return bridges.restart(args.Key)
+}
+
+func (m *bridgeManager) list() []BridgeInfo {
+	m.mu.Lock()
+	entries := make([]*bridgeEntry, 0, len(m.entries))
+	for _, entry := range m.entries {
+		entries = append(entries, entry)
+	}
+	m.mu.Unlock()
+	out := make([]BridgeInfo, 0, len(entries))
+	for _, entry := range entries {
+		out = append(out, entry.info())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+func (e *bridgeEntry) info() BridgeInfo {
+	info := BridgeInfo{
+		Key:               e.spec.Key,
+		Kind:              e.spec.Kind,
+		LocalAddress:      e.spec.LocalAddress,
+		Launcher:          e.spec.Launcher,
+		Argv:              redactArgs(e.spec.Argv),
+		StartedAt:         e.started.Format(time.RFC3339),
+		UptimeMs:          time.Since(e.started).Milliseconds(),
+		ActiveConnections: e.metrics.active.Load(),
+		TotalConnections:  e.metrics.total.Load(),
+		BytesIn:           e.metrics.bytesIn.Load(),
+		BytesOut:          e.metrics.bytesOut.Load(),
+		PooledChildren:    e.pool.readyCount(),
+		LastError:         e.metrics.lastErr(),
+	}
+	if ssh := e.spec.SSH; ssh != nil {
+		host := ssh.Host
+		if host == "" {
+			host = ssh.ConfigHost
+		}
+		info.Target = host
+		if ssh.User != "" {
+			info.Target = ssh.User + "@" + host
+		}
+		if ssh.Port != 0 {
+			info.Target = fmt.Sprintf("%s:%d", info.Target, ssh.Port)
+		}
+	}
+	return info
+}
+
+func (m *bridgeManager) restart(key string) error {
+	m.mu.Lock()
+	entry, ok := m.entries[key]
+	m.mu.Unlock()
+	if !ok {
+		return errors.New("no bridge with key " + key)
+	}
+	m.stop(key)
+	_, err := m.ensure(entry.spec)
+	return err
+}
+
+// meterDial wraps a connection ProxyService dialed to a tunnel's forwarded socket, so tunnels report traffic too.
+// Any other address (a direct socket, or a stdio/ssh bridge already metered on accept) is returned unchanged.
+func (m *bridgeManager) meterDial(address string, conn net.Conn) net.Conn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, entry := range m.entries {
+		if entry.spec.Kind == "tunnel" && entry.spec.LocalAddress == address {
+			return entry.metrics.meter(conn)
+		}
+	}
+	return conn
+}
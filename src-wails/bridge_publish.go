@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Published connections — a stable local socket per app connection under userData/sockets (a named pipe on
+// Windows). The docker/podman CLIs in a terminal can then share the exact connection the app uses. Each accepted
+// connection is relayed to the connection's live target: for a remote, the bridge (ensured on demand, so it
+// follows restarts, unless the user stopped it); otherwise the direct engine socket. Optionally a `docker context`
+// / `podman system connection` named container-desktop-<name> is created pointing at it.
+//
+// Socket and context names are the ID with unsafe characters replaced, plus a short hash of the raw ID, so two IDs
+// that sanitize alike ("a/b", "a:b") never share a socket or a context.
+
+const publishDialTimeout = 10 * time.Second
+
+var publishIDUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// PublishConnectionRequest names the connection (ID, stable across restarts) and its target: Bridge for an
+// SSH/WSL remote (the same spec ProxyService receives), else Socket (a unix:// | npipe:// URI or path). Engine is
+// "docker" | "podman" and picks the CLI entry CreateContext generates; Program overrides the CLI path.
+type PublishConnectionRequest struct {
+	ID            string      `json:"id"`
+	Engine        string      `json:"engine"`
+	Bridge        *bridgeSpec `json:"bridge,omitempty"`
+	Socket        string      `json:"socket,omitempty"`
+	CreateContext bool        `json:"createContext"`
+	Program       string      `json:"program,omitempty"`
+}
+
+// PublishedConnection is one published socket. URI is what DOCKER_HOST / CONTAINER_HOST take; Context is the
+// generated CLI entry ("" when none) and ContextError why generating it failed (the socket is published anyway).
+type PublishedConnection struct {
+	ID           string `json:"id"`
+	Engine       string `json:"engine"`
+	LocalAddress string `json:"localAddress"`
+	URI          string `json:"uri"`
+	Target       string `json:"target"`
+	Context      string `json:"context,omitempty"`
+	ContextError string `json:"contextError,omitempty"`
+	PublishedAt  string `json:"publishedAt"`
+}
+
+type unpublishConnectionArgs struct {
+	ID            string `json:"id"`
+	RemoveContext bool   `json:"removeContext"`
+}
+
+type publishedSocket struct {
+	info     PublishedConnection
+	request  PublishConnectionRequest
+	listener net.Listener
+}
+
+// publishRegistry holds the published sockets. Replacing an earlier publish of an ID and binding its socket happen
+// in one critical section under mu, so concurrent publishes of one ID never race for the socket or leak a listener.
+type publishRegistry struct {
+	mu      sync.Mutex
+	entries map[string]*publishedSocket
+}
+
+// published is shared like bridges: one set of published sockets for the app's lifetime.
+var published = &publishRegistry{}
+
+// PublishConnection binds (or re-binds, replacing an earlier publish of the same ID) the connection's local
+// socket and starts relaying. Publishing the same ID again is how the target is updated.
+func (s *BridgeService) PublishConnection(req PublishConnectionRequest) (PublishedConnection, error) {
+	return published.publish(req, bridges)
+}
+
+// UnpublishConnection closes a published socket and, with RemoveContext, deletes its generated CLI entry.
+func (s *BridgeService) UnpublishConnection(args unpublishConnectionArgs) error {
+	return published.unpublish(args.ID, args.RemoveContext)
+}
+
+// ListPublishedConnections reports the published sockets, sorted by ID.
+func (s *BridgeService) ListPublishedConnections() []PublishedConnection {
+	return published.list()
+}
+
+func (r *publishRegistry) publish(req PublishConnectionRequest, manager *bridgeManager) (PublishedConnection, error) {
+	if req.ID == "" {
+		return PublishedConnection{}, errors.New("publish: connection id is empty")
+	}
+	if req.Bridge == nil && stripSocketScheme(req.Socket) == "" {
+		return PublishedConnection{}, errors.New("publish: neither a bridge nor a socket to relay to")
+	}
+	address, err := publishedSocketAddress(req.ID)
+	if err != nil {
+		return PublishedConnection{}, err
+	}
+	r.mu.Lock()
+	if previous, ok := r.entries[req.ID]; ok {
+		delete(r.entries, req.ID)
+		previous.close()
+	}
+	listener, err := newStdioListener(address)
+	if err != nil {
+		r.mu.Unlock()
+		return PublishedConnection{}, err
+	}
+	entry := &publishedSocket{request: req, listener: listener, info: PublishedConnection{
+		ID:           req.ID,
+		Engine:       req.Engine,
+		LocalAddress: address,
+		URI:          socketURI(address),
+		Target:       publishTarget(req),
+		PublishedAt:  time.Now().Format(time.RFC3339),
+	}}
+	if r.entries == nil {
+		r.entries = map[string]*publishedSocket{}
+	}
+	r.entries[req.ID] = entry
+	r.mu.Unlock()
+	go entry.serve(manager)
+	if !req.CreateContext {
+		return entry.info, nil
+	}
+	// The CLI can take seconds, so it runs outside the lock; the info is updated under it.
+	name, err := createCLIContext(req, entry.info.URI)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry.info.Context = name
+	if err != nil {
+		entry.info.ContextError = err.Error()
+	}
+	return entry.info, nil
+}
+
+func (r *publishRegistry) unpublish(id string, removeContext bool) error {
+	r.mu.Lock()
+	entry, ok := r.entries[id]
+	delete(r.entries, id)
+	var info PublishedConnection
+	if ok {
+		entry.close()
+		info = entry.info
+	}
+	r.mu.Unlock()
+	if !ok {
+		return errors.New("no published connection with id " + id)
+	}
+	if removeContext && info.Context != "" {
+		return removeCLIContext(entry.request, info.Context)
+	}
+	return nil
+}
+
+// close stops accepting and removes the socket (r.mu held, so a new bind of the address cannot interleave).
+func (p *publishedSocket) close() {
+	_ = p.listener.Close()
+	removeLocalSocket(p.info.LocalAddress)
+}
+
+func (r *publishRegistry) list() []PublishedConnection {
+	r.mu.Lock()
+	out := make([]PublishedConnection, 0, len(r.entries))
+	for _, entry := range r.entries {
+		out = append(out, entry.info)
+	}
+	r.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func (p *publishedSocket) serve(manager *bridgeManager) {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		go p.relay(conn, manager)
+	}
+}
+
+// relay resolves the target per connection (ensuring the bridge, which reuses it when already up) and shuttles
+// raw bytes to it. A tunnel's forwarded socket is metered like ProxyService's own dials.
+func (p *publishedSocket) relay(conn net.Conn, manager *bridgeManager) {
+	defer func() { _ = conn.Close() }()
+	var target string
+	if p.request.Bridge != nil {
+		address, err := manager.ensurePublished(*p.request.Bridge)
+		if err != nil {
+			appendLogLine("WARN", "[publish:"+p.info.ID+"] "+err.Error())
+			return
+		}
+		target = address
+	} else {
+		target = flatpakRemap(stripSocketScheme(p.request.Socket))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), publishDialTimeout)
+	upstream, err := dialLocalTransport(ctx, target)
+	cancel()
+	if err != nil {
+		appendLogLine("WARN", "[publish:"+p.info.ID+"] "+err.Error())
+		return
+	}
+	upstream = manager.meterDial(target, upstream)
+	defer func() { _ = upstream.Close() }()
+	shuttle(conn, upstream, func() { closeWrite(upstream) }, upstream)
+}
+
+// publishedSocketAddress is userData/sockets/<name>.sock (just a hash of the id when that would overflow
+// sun_path), or the named pipe //./pipe/container-desktop-<name> on Windows.
+func publishedSocketAddress(id string) (string, error) {
+	name := publishName(id)
+	if runtime.GOOS == "windows" {
+		return "//./pipe/container-desktop-" + name, nil
+	}
+	base, err := userDataPath()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(base, "sockets")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name+".sock")
+	if len(path) > maxSocketPathLength {
+		sum := sha256.Sum256([]byte(id))
+		path = filepath.Join(dir, hex.EncodeToString(sum[:6])+".sock")
+	}
+	return path, nil
+}
+
+func socketURI(address string) string {
+	if strings.HasPrefix(address, "//./pipe/") {
+		return "npipe://" + address
+	}
+	return "unix://" + address
+}
+
+func publishTarget(req PublishConnectionRequest) string {
+	if req.Bridge != nil {
+		return req.Bridge.Kind + " bridge " + req.Bridge.Key
+	}
+	return req.Socket
+}
+
+// publishName is the id made safe for socket and context names — unsafe runs replaced by "-" — and suffixed with
+// a short hash of the raw id, which keeps ids that sanitize alike apart.
+func publishName(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	suffix := hex.EncodeToString(sum[:4])
+	if name := strings.Trim(publishIDUnsafe.ReplaceAllString(id, "-"), "-."); name != "" {
+		return name + "-" + suffix
+	}
+	return suffix
+}
+
+func cliContextName(id string) string {
+	return "container-desktop-" + publishName(id)
+}
+
+// createCLIContext points a docker context / podman system connection named container-desktop-<name> at uri,
+// replacing one left by an earlier publish.
+func createCLIContext(req PublishConnectionRequest, uri string) (string, error) {
+	name := cliContextName(req.ID)
+	program := cliProgram(req)
+	switch req.Engine {
+	case "docker":
+		if runCLI(program, "context", "inspect", name) == nil {
+			return name, runCLI(program, "context", "update", name, "--docker", "host="+uri)
+		}
+		return name, runCLI(program, "context", "create", name, "--description", "Container Desktop ("+req.ID+")", "--docker", "host="+uri)
+	case "podman":
+		_ = runCLI(program, "system", "connection", "remove", name)
+		return name, runCLI(program, "system", "connection", "add", name, uri)
+	default:
+		return "", fmt.Errorf("no CLI context for engine %q", req.Engine)
+	}
+}
+
+func removeCLIContext(req PublishConnectionRequest, name string) error {
+	program := cliProgram(req)
+	if req.Engine == "podman" {
+		return runCLI(program, "system", "connection", "remove", name)
+	}
+	return runCLI(program, "context", "rm", "--force", name)
+}
+
+func cliProgram(req PublishConnectionRequest) string {
+	if req.Program != "" {
+		return req.Program
+	}
+	return req.Engine
+}
+
+// runCLI runs one short engine CLI command, folding its output into the error when it fails.
+func runCLI(program string, args ...string) error {
+	if err := launchPolicy.authorize(commandRequest{"publish", program, args, "", ""}); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), publishDialTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, program, args...)
+	configureHiddenWindow(cmd)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if text := strings.TrimSpace(string(output)); text != "" {
+			return fmt.Errorf("%s %s: %w: %s", program, strings.Join(args, " "), err, text)
+		}
+		return fmt.Errorf("%s %s: %w", program, strings.Join(args, " "), err)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+//go:build !windows
+
+package main
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A published connection relays its userData socket to the direct engine socket, and CreateContext drives the
+// engine CLI (a fake docker here, which has no existing context) to point a context at it.
+func TestPublishConnectionRelaysAndCreatesContext(t *testing.T) {
+	// Short enough that the named socket fits sun_path (t.TempDir would fall back to the bare hash).
+	dir, err := os.MkdirTemp("", "publish")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	t.Setenv("CONTAINER_DESKTOP_USER_DATA_DIR", dir)
+	engine := filepath.Join(t.TempDir(), "engine.sock")
+	listener, err := net.Listen("unix", engine)
+	if err != nil {
+		t.Fatalf("listen engine: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				data, _ := io.ReadAll(conn)
+				_, _ = conn.Write([]byte("engine:" + string(data)))
+			}()
+		}
+	}()
+	log := filepath.Join(dir, "docker.log")
+	docker := filepath.Join(t.TempDir(), "docker")
+	script := "#!/bin/sh\necho \"$*\" >> " + log + "\n[ \"$2\" = inspect ] && exit 1\nexit 0\n"
+	if err := os.WriteFile(docker, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := &publishRegistry{}
+	info, err := registry.publish(PublishConnectionRequest{
+		ID: "conn 1", Engine: "docker", Socket: "unix://" + engine, CreateContext: true, Program: docker,
+	}, &bridgeManager{})
+	if err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	name := publishName("conn 1")
+	if !strings.HasPrefix(name, "conn-1-") {
+		t.Fatalf("publish name = %q, want the sanitized id plus a hash", name)
+	}
+	if want := filepath.Join(dir, "sockets", name+".sock"); info.LocalAddress != want || info.URI != "unix://"+want {
+		t.Fatalf("published at %q (%q), want %q", info.LocalAddress, info.URI, want)
+	}
+	if info.Context != "container-desktop-"+name || info.ContextError != "" {
+		t.Fatalf("context = %q (%q)", info.Context, info.ContextError)
+	}
+	if got := roundTrip(t, info.LocalAddress, "ping"); got != "engine:ping" {
+		t.Fatalf("relayed reply = %q", got)
+	}
+
+	if err := registry.unpublish("conn 1", true); err != nil {
+		t.Fatalf("unpublish: %v", err)
+	}
+	if fileExists(info.LocalAddress) {
+		t.Fatal("published socket left behind")
+	}
+	raw, _ := os.ReadFile(log)
+	want := strings.Join([]string{
+		"context inspect " + info.Context,
+		"context create " + info.Context + " --description Container Desktop (conn 1) --docker host=" + info.URI,
+		"context rm --force " + info.Context,
+	}, "\n")
+	if got := strings.TrimSpace(string(raw)); got != want {
+		t.Fatalf("docker calls =\n%s\nwant\n%s", got, want)
+	}
+	if len(registry.list()) != 0 {
+		t.Fatal("unpublished connection still listed")
+	}
+}
+
+// IDs that sanitize to the same name still get their own sockets.
+func TestPublishedSocketAddressesDoNotCollide(t *testing.T) {
+	t.Setenv("CONTAINER_DESKTOP_USER_DATA_DIR", t.TempDir())
+	seen := map[string]string{}
+	for _, id := range []string{"a/b", "a:b", "a b", "a-b", "/", ":"} {
+		address, err := publishedSocketAddress(id)
+		if err != nil {
+			t.Fatalf("%q: %v", id, err)
+		}
+		if other, ok := seen[address]; ok {
+			t.Fatalf("%q and %q both publish at %s", id, other, address)
+		}
+		seen[address] = id
+	}
+}
+
+// A published socket does not bring back a bridge the user stopped; the app ensuring it again lifts that.
+func TestPublishedRelaySkipsStoppedBridge(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CONTAINER_DESKTOP_USER_DATA_DIR", dir)
+	manager := &bridgeManager{}
+	spec := bridgeSpec{Kind: "stdio", Key: "remote", LocalAddress: filepath.Join(dir, "bridge.sock"), Launcher: "cat"}
+	registry := &publishRegistry{}
+	info, err := registry.publish(PublishConnectionRequest{ID: "remote", Engine: "docker", Bridge: &spec}, manager)
+	if err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	defer func() { _ = registry.unpublish("remote", false) }()
+	defer manager.stop("remote")
+	if got := roundTrip(t, info.LocalAddress, "up"); got != "up" {
+		t.Fatalf("relayed reply = %q", got)
+	}
+
+	manager.hold("remote")
+	manager.stop("remote")
+	conn, err := net.Dial("unix", info.LocalAddress)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	_, _ = conn.Write([]byte("held"))
+	_ = conn.(*net.UnixConn).CloseWrite()
+	if echoed, _ := io.ReadAll(conn); len(echoed) != 0 {
+		t.Fatalf("relayed reply = %q through a stopped bridge", echoed)
+	}
+	_ = conn.Close()
+	if len(manager.list()) != 0 {
+		t.Fatal("the relay restarted a bridge the user stopped")
+	}
+
+	if _, err := manager.ensure(spec); err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+	if got := roundTrip(t, info.LocalAddress, "again"); got != "again" {
+		t.Fatalf("relayed reply = %q after the app reconnected", got)
+	}
+}
+
+// Concurrent publishes of one ID all succeed and leave exactly one live socket, which unpublish then closes.
+func TestConcurrentPublishOfOneID(t *testing.T) {
+	dir, err := os.MkdirTemp("", "publish")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	t.Setenv("CONTAINER_DESKTOP_USER_DATA_DIR", dir)
+	engine := filepath.Join(dir, "engine.sock")
+	registry := &publishRegistry{}
+	errs := make(chan error, 8)
+	for range 8 {
+		go func() {
+			_, err := registry.publish(PublishConnectionRequest{ID: "same", Engine: "docker", Socket: "unix://" + engine}, &bridgeManager{})
+			errs <- err
+		}()
+	}
+	for range 8 {
+		if err := <-errs; err != nil {
+			t.Errorf("publish: %v", err)
+		}
+	}
+	list := registry.list()
+	if len(list) != 1 {
+		t.Fatalf("published = %+v, want one", list)
+	}
+	registry.mu.Lock()
+	listener := registry.entries["same"].listener
+	registry.mu.Unlock()
+	if err := registry.unpublish("same", false); err != nil {
+		t.Fatalf("unpublish: %v", err)
+	}
+	if _, err := listener.Accept(); err == nil {
+		t.Error("the registered listener still accepts after unpublish")
+	}
+	if conn, err := net.Dial("unix", list[0].LocalAddress); err == nil {
+		_ = conn.Close()
+		t.Error("a listener outlived unpublish")
+	}
+}
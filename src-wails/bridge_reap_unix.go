@@ -0,0 +1,98 @@
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const reapGracePeriod = time.Second
+
+// processRunning reports whether pid is alive AND still runs launcher, so a reused PID is never mistaken for our
+// child. The names compared are the full ones, never ps's comm, which Linux truncates to 15 characters.
+func processRunning(pid int, launcher string) bool {
+	if pid <= 0 || syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	want := map[string]bool{filepath.Base(launcher): true}
+	if path, err := resolveLauncher(launcher, ""); err == nil {
+		want[filepath.Base(path)] = true
+	}
+	for _, name := range processNames(pid) {
+		if want[filepath.Base(name)] {
+			return true
+		}
+	}
+	return false
+}
+
+// processNames are the executable paths pid runs as: from /proc its binary and its argv[0] (which differ for a
+// symlinked launcher), elsewhere ps's comm, which macOS and the BSDs report untruncated.
+func processNames(pid int) []string {
+	proc := filepath.Join("/proc", strconv.Itoa(pid))
+	if cmdline, err := os.ReadFile(filepath.Join(proc, "cmdline")); err == nil {
+		names := []string{}
+		if argv0, _, _ := strings.Cut(string(cmdline), "\x00"); argv0 != "" {
+			names = append(names, argv0)
+		}
+		if exe, err := os.Readlink(filepath.Join(proc, "exe")); err == nil {
+			names = append(names, strings.TrimSuffix(exe, " (deleted)"))
+		}
+		return names
+	}
+	output, err := exec.Command("ps", "-o", "comm=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return nil
+	}
+	return []string{strings.TrimSpace(string(output))}
+}
+
+// processStartTime identifies when pid started, to tell it from a later process given the same PID: field 22 of
+// /proc/<pid>/stat (clock ticks since boot) on Linux, ps's lstart elsewhere. "" when it cannot be read.
+func processStartTime(pid int) string {
+	if pid <= 0 {
+		return ""
+	}
+	if stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat")); err == nil {
+		// The name in field 2 may hold spaces and parentheses; the fields after its closing ")" start at field 3.
+		_, rest, found := bytesCutLast(stat, ')')
+		fields := strings.Fields(rest)
+		if !found || len(fields) < 20 {
+			return ""
+		}
+		return fields[19]
+	}
+	output, err := exec.Command("ps", "-o", "lstart=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(string(output)), " ")
+}
+
+func bytesCutLast(data []byte, sep byte) (before, after string, found bool) {
+	index := bytes.LastIndexByte(data, sep)
+	if index < 0 {
+		return string(data), "", false
+	}
+	return string(data[:index]), string(data[index+1:]), true
+}
+
+// terminateProcess sends SIGTERM, and SIGKILL if the process outlives the grace period.
+func terminateProcess(pid int) {
+	_ = syscall.Kill(pid, syscall.SIGTERM)
+	deadline := time.Now().Add(reapGracePeriod)
+	for time.Now().Before(deadline) {
+		if syscall.Kill(pid, 0) != nil {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	_ = syscall.Kill(pid, syscall.SIGKILL)
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package main
+
+// Windows bridges are named pipes served by short-lived dial-stdio children. There are no `ssh -NL` tunnels and
+// no ControlMasters, so nothing long-lived is recorded and there is nothing to reap.
+
+func processRunning(_ int, _ string) bool {
+	return false
+}
+
+func processStartTime(_ int) string {
+	return ""
+}
+
+func terminateProcess(_ int) {}
@@ -6,7 +6,6 @@ import (
 	"io"
 	"net"
 	"os"
-	"os/exec"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -18,9 +17,10 @@ import (
 // localAddress, launcher, argv }. ProxyService dials localAddress after ensure. Bridges are cached by key (reused
 // across a webview reload / reconnect); proxy_bridge_stop tears one down.
 //
-// Two kinds: "stdio" = a local listener (unix socket / windows named pipe) that per incoming connection spawns
+// Three kinds: "stdio" = a local listener (unix socket / windows named pipe) that per incoming connection spawns
 // `launcher argv` and shuttles RAW bytes both ways (the dial-stdio bridge); "tunnel" = a long-lived `ssh -NL`
-// child whose forwarded local socket is dialed once it appears.
+// child whose forwarded local socket is dialed once it appears; "ssh" = the same local listener served by an
+// in-process SSH client instead of the system `ssh` binary (bridge_ssh.go).
 type BridgeService struct{}
 
 // bridges is the shared manager: ProxyService.resolveProxyTarget ensures, BridgeService.Stop stops. Package-level
@@ -30,155 +30,349 @@ var bridges = &bridgeManager{}
 type bridgeManager struct {
 	mu      sync.Mutex
 	entries map[string]*bridgeEntry
+	// stderr outlives its entry (a failed start leaves nothing in entries, but its output is what explains the
+	// failure); the next ensure for the key keeps appending to the same ring.
+	stderr map[string]*bridgeStderr
+	// masters are the shared SSH ControlMaster connections of stdio bridges, by ControlPath (bridge_ssh_master.go).
+	masters map[string]*sshMaster
+	// starting holds the keys whose bridge ensure is bringing up outside the lock.
+	starting map[string]*bridgeStart
+	// held are the keys the user stopped (BridgeService.Stop). A published socket's relay does not bring those
+	// back; the app connecting again (ProxyService's ensure) does, and clears the hold.
+	held map[string]bool
+	// forwards are the user's port forwards by id, each tied to a bridge key (bridge_forward.go).
+	forwards       map[string]*portForward
+	forwardCounter atomic.Uint64
+	// statePath is the crash-recovery record (bridge_state.go); empty ⇒ not persisted.
+	statePath string
+	// emit: test override, else the live Wails app emitter (see events.go) — carries the bridge://state events.
+	emit func(name string, data any)
 }
 
+// bridgeEntry is one live bridge. ensure creates it with the spec, stderr ring and metrics; the kind's start
+// function then sets stop. A stdio bridge over ssh also holds its ControlMaster and the argv rewritten to use it,
+// a stdio bridge may hold a pool of pre-spawned children, an ssh bridge its client (port forwards dial on it), and
+// a tunnel its supervisor (whose child PID the crash-recovery record holds).
 type bridgeEntry struct {
-	stop func()
+	spec    bridgeSpec
+	started time.Time
+	stderr  *bridgeStderr
+	metrics bridgeMetrics
+	argv    []string
+	master  *sshMaster
+	pool    *stdioPool
+	ssh     *sshTransport
+	tunnel  *tunnelSupervisor
+	stop    func()
+}
+
+// bridgeStart is an ensure in progress. done closes once it has settled, with err set on failure; stopped is set
+// (under m.mu) by a Stop that arrived meanwhile.
+type bridgeStart struct {
+	done    chan struct{}
+	err     error
+	stopped bool
 }
 
 type proxyBridgeStopArgs struct {
 	Key string `json:"key"`
 }
 
+type bridgeKeyArgs struct {
+	Key string `json:"key"`
+}
+
+// Stderr returns the retained stderr of a bridge's children (the dial-stdio relays, the ssh -NL tunnel, or the
+// in-process SSH sessions) — "" for an unknown key. Kept after a failed start or a stop, until the app exits.
+func (s *BridgeService) Stderr(args bridgeKeyArgs) string {
+	return bridges.stderrOutput(args.Key)
+}
+
 // Stop tears down a connection's bridge by cache key (relay for SSH, connection id for WSL). The JS binding calls
 // it for both candidate keys; the non-matching one is a no-op. Mirrors bridge.rs proxy_bridge_stop.
 func (s *BridgeService) Stop(args proxyBridgeStopArgs) {
+	bridges.hold(args.Key)
 	bridges.stop(args.Key)
 }
 
 // ensure brings the bridge up (or reuses a cached one) and returns the LOCAL socket/pipe path ProxyService dials.
+// Starting can take seconds (a tunnel waits for the engine's /_ping, ssh may be slow to fail), so it runs outside
+// m.mu: the key is parked in m.starting meanwhile, and a concurrent ensure for it waits for that start and shares
+// its result (the launch policy's decision included) instead of racing it. Every other bridge — and List, Stop,
+// Restart — stays available.
 func (m *bridgeManager) ensure(spec bridgeSpec) (string, error) {
+	return m.ensureBridge(spec, true)
+}
+
+// ensurePublished is ensure for a published socket's relay: a bridge the user stopped stays stopped.
+func (m *bridgeManager) ensurePublished(spec bridgeSpec) (string, error) {
+	return m.ensureBridge(spec, false)
+}
+
+func (m *bridgeManager) ensureBridge(spec bridgeSpec, resume bool) (string, error) {
 	if spec.LocalAddress == "" {
 		return "", errors.New("bridge localAddress is empty (the remote connection has no local forward socket)")
 	}
+	switch spec.Kind {
+	case "stdio", "tunnel":
+	case "ssh":
+		if spec.SSH == nil {
+			return "", errors.New("ssh bridge: missing ssh settings")
+		}
+	default:
+		return "", fmt.Errorf("unknown bridge kind: %s", spec.Kind)
+	}
 	m.mu.Lock()
-	defer m.mu.Unlock()
-	if m.entries == nil {
-		m.entries = map[string]*bridgeEntry{}
+	if resume {
+		delete(m.held, spec.Key)
+	} else if m.held[spec.Key] {
+		m.mu.Unlock()
+		return "", errors.New("bridge " + spec.Key + " was stopped by the user")
 	}
 	if _, ok := m.entries[spec.Key]; ok {
+		m.mu.Unlock()
+		return spec.LocalAddress, nil
+	}
+	if pending, ok := m.starting[spec.Key]; ok {
+		m.mu.Unlock()
+		<-pending.done
+		if pending.err != nil {
+			return "", pending.err
+		}
 		return spec.LocalAddress, nil
 	}
-	var entry *bridgeEntry
+	if m.starting == nil {
+		m.starting = map[string]*bridgeStart{}
+	}
+	pending := &bridgeStart{done: make(chan struct{})}
+	m.starting[spec.Key] = pending
+	if m.stderr == nil {
+		m.stderr = map[string]*bridgeStderr{}
+	}
+	stderr, ok := m.stderr[spec.Key]
+	if !ok {
+		stderr = newBridgeStderr(spec.Key)
+		m.stderr[spec.Key] = stderr
+	}
+	entry := &bridgeEntry{spec: spec, started: time.Now(), stderr: stderr}
+	m.mu.Unlock()
+
+	// The stdio and tunnel kinds run spec.Launcher (per connection, or supervised); "ssh" is in-process, and is
+	// authorized as the ssh command line it stands in for. Only the ensure that registered the start authorizes
+	// it, outside the lock since the policy may wait on the user: the ensures sharing the start share its
+	// decision, so the user is asked once and it is audited once.
+	request := commandRequest{"bridge", spec.Launcher, spec.Argv, "", ""}
+	if spec.Kind == "ssh" {
+		request = commandRequest{"bridge-ssh", "ssh", sshBridgeArgv(resolveSSHBridgeSpec(*spec.SSH)), "", ""}
+	}
+	if err := launchPolicy.authorize(request); err != nil {
+		m.mu.Lock()
+		delete(m.starting, spec.Key)
+		m.mu.Unlock()
+		pending.err = err
+		close(pending.done)
+		return "", err
+	}
 	var err error
 	switch spec.Kind {
 	case "stdio":
-		entry, err = startStdioBridge(spec)
+		entry.argv = m.multiplexSSH(entry)
+		err = startStdioBridge(entry)
 	case "tunnel":
-		entry, err = startTunnel(spec)
-	default:
-		return "", fmt.Errorf("unknown bridge kind: %s", spec.Kind)
+		err = m.startTunnel(entry)
+	case "ssh":
+		err = startSSHBridge(entry)
+	}
+
+	m.mu.Lock()
+	delete(m.starting, spec.Key)
+	stopped := err == nil && pending.stopped
+	if err == nil && !stopped {
+		if m.entries == nil {
+			m.entries = map[string]*bridgeEntry{}
+		}
+		m.entries[spec.Key] = entry
+		m.persistStateLocked()
+	}
+	m.mu.Unlock()
+	switch {
+	case stopped:
+		// Stop arrived while it was starting: honor it now that there is something to stop.
+		entry.stop()
+		err = errors.New("bridge " + spec.Key + " was stopped while starting")
+	case err != nil:
+		err = stderr.withTail(err)
 	}
+	if err != nil {
+		m.releaseSSHMaster(entry)
+	}
+	pending.err = err
+	close(pending.done)
 	if err != nil {
 		return "", err
 	}
-	m.entries[spec.Key] = entry
 	return spec.LocalAddress, nil
 }
 
+// forget drops a bridge whose supervisor gave up, so the next ensure starts it afresh instead of reusing a dead
+// entry, and closes its port forwards. Only that exact entry — a newer bridge under the same key is left alone.
+func (m *bridgeManager) forget(key string, entry *bridgeEntry) {
+	m.mu.Lock()
+	current := m.entries[key] == entry
+	if current {
+		delete(m.entries, key)
+	}
+	m.mu.Unlock()
+	if current {
+		m.closeForwards(key)
+		m.persistState()
+	}
+}
+
+func (m *bridgeManager) stderrFor(key string) *bridgeStderr {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stderr[key]
+}
+
+func (m *bridgeManager) stderrOutput(key string) string {
+	if stderr := m.stderrFor(key); stderr != nil {
+		return stderr.String()
+	}
+	return ""
+}
+
+// hold marks a key the user stopped, until the next ensure (see bridgeManager.held).
+func (m *bridgeManager) hold(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.held == nil {
+		m.held = map[string]bool{}
+	}
+	m.held[key] = true
+}
+
 func (m *bridgeManager) stop(key string) {
 	m.mu.Lock()
 	entry, ok := m.entries[key]
 	if ok {
 		delete(m.entries, key)
 	}
+	if pending := m.starting[key]; pending != nil {
+		pending.stopped = true
+	}
 	m.mu.Unlock()
 	if ok {
+		m.closeForwards(key)
 		entry.stop()
+		m.releaseSSHMaster(entry)
+		m.persistState()
 	}
 }
 
-// startStdioBridge binds the platform listener (newStdioListener) and accepts forever, spawning a per-connection
-// dial-stdio relay. stop() closes the listener (ending accept) + removes the unix socket.
-func startStdioBridge(spec bridgeSpec) (*bridgeEntry, error) {
+// startStdioBridge binds the platform listener (newStdioListener) and accepts forever, relaying each connection
+// (metered) through a dial-stdio child — a pre-spawned one from the pool when configured, else spawned with
+// entry.argv, through the SSH master (revived if it died). stop() closes the listener (ending accept), drains the
+// pool, and removes the unix socket.
+func startStdioBridge(entry *bridgeEntry) error {
+	spec := entry.spec
 	listener, err := newStdioListener(spec.LocalAddress)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	pool := newStdioPool(entry)
 	go func() {
 		for {
 			conn, acceptErr := listener.Accept()
 			if acceptErr != nil {
 				return
 			}
-			go bridgeConnection(conn, spec.Launcher, spec.Argv)
+			if entry.master != nil {
+				entry.master.revive()
+			}
+			go bridgeConnection(entry.metrics.meter(conn), entry, pool)
 		}
 	}()
-	address := spec.LocalAddress
-	return &bridgeEntry{stop: func() {
+	entry.pool = pool
+	entry.stop = func() {
 		_ = listener.Close()
-		removeLocalSocket(address)
-	}}, nil
+		pool.close()
+		removeLocalSocket(spec.LocalAddress)
+	}
+	return nil
 }
 
-// bridgeConnection spawns `launcher argv` and shuttles RAW bytes conn↔child-stdio both ways until each side hits
-// EOF (mirrors Node's .pipe() half-close forwarding). Nothing is decoded. Mirrors bridge.rs bridge_*_connection.
-func bridgeConnection(conn net.Conn, launcher string, argv []string) {
+// bridgeConnection takes a ready child from the pool (or spawns `launcher argv`) and shuttles RAW bytes
+// conn↔child-stdio both ways until each side hits EOF (mirrors Node's .pipe() half-close forwarding). Nothing is
+// decoded; the child's stderr goes to the bridge's stderr ring and a spawn failure becomes its last error. Mirrors
+// bridge.rs bridge_*_connection.
+func bridgeConnection(conn net.Conn, entry *bridgeEntry, pool *stdioPool) {
 	defer func() { _ = conn.Close() }()
-	cmd := exec.Command(launcher, argv...)
-	configureHiddenWindow(cmd)
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return
-	}
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return
+	child := pool.take()
+	pooled := child != nil
+	if !pooled {
+		var err error
+		if child, err = spawnStdioChild(entry.spec.Launcher, entry.argv, entry.stderr); err != nil {
+			entry.metrics.fail(err)
+			return
+		}
 	}
-	if err := cmd.Start(); err != nil {
-		return
+	// On client EOF, close the child's stdin (a pipe signals EOF only by closing the write fd).
+	replies := &countingReader{reader: child.stdout}
+	shuttle(conn, child.stdin, func() { _ = child.stdin.Close() }, replies)
+	child.kill()
+	if pooled && replies.count > 0 {
+		pool.served()
 	}
+}
+
+// countingReader counts the bytes read through it (single reader).
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// shuttle copies conn → upstream and upstream → conn until both directions hit EOF. closeUpstream signals EOF
+// upstream once the client is done; on upstream EOF the connection's write side is half-closed (a unix socket /
+// named pipe honors CloseWrite). Shared by the child-process and in-process SSH bridges. Returns the first copy
+// error that is not just one side closing.
+func shuttle(conn net.Conn, upstream io.Writer, closeUpstream func(), downstream io.Reader) error {
 	var both sync.WaitGroup
+	errs := make(chan error, 2)
 	both.Add(2)
-	// client → daemon; on client EOF, close the child's stdin (a pipe signals EOF only by closing the write fd).
 	go func() {
 		defer both.Done()
-		_, _ = io.Copy(stdin, conn)
-		_ = stdin.Close()
+		_, err := io.Copy(upstream, conn)
+		errs <- err
+		closeUpstream()
 	}()
-	// daemon → client; on EOF, half-close the connection's write side (a unix socket / named pipe honors CloseWrite).
 	go func() {
 		defer both.Done()
-		_, _ = io.Copy(conn, stdout)
-		if halfCloser, ok := conn.(interface{ CloseWrite() error }); ok {
-			_ = halfCloser.CloseWrite()
-		}
+		_, err := io.Copy(conn, downstream)
+		errs <- err
+		closeWrite(conn)
 	}()
 	both.Wait()
-	_ = cmd.Process.Kill()
-	_ = cmd.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil && !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.ErrClosedPipe) && !errors.Is(err, os.ErrClosed) {
+			return err
+		}
+	}
+	return nil
 }
 
-// startTunnel spawns a long-lived `ssh -NL` child and polls (~5s) for the forwarded local socket to appear,
-// failing fast if the ssh child exits first (auth/host error). Mirrors bridge.rs start_tunnel.
-func startTunnel(spec bridgeSpec) (*bridgeEntry, error) {
-	removeLocalSocket(spec.LocalAddress)
-	cmd := exec.Command(spec.Launcher, spec.Argv...)
-	configureHiddenWindow(cmd)
-	if err := cmd.Start(); err != nil {
-		return nil, err
-	}
-	exited := &atomic.Bool{}
-	go func() {
-		_ = cmd.Wait()
-		exited.Store(true)
-	}()
-	for range 50 {
-		if _, statErr := os.Stat(spec.LocalAddress); statErr == nil {
-			process := cmd.Process
-			address := spec.LocalAddress
-			return &bridgeEntry{stop: func() {
-				_ = process.Kill()
-				removeLocalSocket(address)
-			}}, nil
-		}
-		if exited.Load() {
-			break
-		}
-		time.Sleep(100 * time.Millisecond)
+// closeWrite half-closes a connection when it supports it (unix sockets, named pipes, SSH channels).
+func closeWrite(conn any) {
+	if halfCloser, ok := conn.(interface{ CloseWrite() error }); ok {
+		_ = halfCloser.CloseWrite()
 	}
-	_ = cmd.Process.Kill()
-	return nil, errors.New("ssh -NL tunnel: local forward socket did not appear")
 }
 
 // removeLocalSocket unlinks a stale unix socket (no-op for a Windows named pipe, which is not a filesystem entry).
@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -48,3 +49,29 @@ func TestStdioBridgeRoundTrip(t *testing.T) {
 		t.Fatalf("socket still present after stop: %v", err)
 	}
 }
+
+// A tunnel child that dies during startup surfaces its stderr: in the ensure error, in the per-bridge stderr
+// ring (BridgeService.Stderr), and in the app log file.
+func TestBridgeChildStderrIsCaptured(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CONTAINER_DESKTOP_USER_DATA_DIR", dir)
+	manager := &bridgeManager{emit: func(string, any) {}}
+	spec := bridgeSpec{
+		Kind:         "tunnel",
+		Key:          "relay-auth",
+		LocalAddress: filepath.Join(dir, "tunnel.sock"),
+		Launcher:     "sh",
+		Argv:         []string{"-c", "echo 'debug1: connecting' >&2; echo 'user@host: Permission denied (publickey).' >&2; exit 255"},
+	}
+	_, err := manager.ensure(spec)
+	if err == nil || !strings.Contains(err.Error(), "Permission denied (publickey)") {
+		t.Fatalf("ensure err = %v, want the ssh stderr tail", err)
+	}
+	if output := manager.stderrOutput("relay-auth"); !strings.Contains(output, "debug1: connecting") {
+		t.Fatalf("stderr ring = %q", output)
+	}
+	logged, err := os.ReadFile(filepath.Join(dir, "logs", "container-desktop.log"))
+	if err != nil || !strings.Contains(string(logged), "[bridge:relay-auth] user@host: Permission denied (publickey).") {
+		t.Fatalf("app log = %q (err %v)", logged, err)
+	}
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// The "ssh" bridge kind — an in-process SSH client (golang.org/x/crypto/ssh) instead of the system `ssh` binary,
+// for hosts with no OpenSSH client (a minimal Flatpak) and so auth/host-key failures come back as real errors.
+// Same local side as the stdio bridge (newStdioListener); per accepted connection it either execs the dial-stdio
+// command on a fresh session (Command set — the in-process `ssh host -- docker system dial-stdio`) or opens a
+// direct-streamlocal channel to RemoteSocket (the in-process `ssh -NL`). One SSH connection is shared by every
+// per-connection session/channel and is redialed lazily after a keepalive failure.
+
+const (
+	sshDialTimeout       = 15 * time.Second
+	sshDefaultKeepalive  = 30 * time.Second
+	sshKeepaliveDeadline = 15 * time.Second
+)
+
+// sshBridgeSpec mirrors the `ssh` object of src/platform/wails/exec/proxy-request.ts BridgeSpec (kind "ssh"). Host/
+// Port/User/IdentityFile are the SSHHost fields; ConfigHost fills any of them left empty from ~/.ssh/config.
+type sshBridgeSpec struct {
+	Host           string `json:"host"`
+	Port           uint32 `json:"port"`
+	User           string `json:"user"`
+	IdentityFile   string `json:"identityFile"`
+	ConfigHost     string `json:"configHost"`
+	KnownHostsFile string `json:"knownHostsFile"`
+	ForwardAgent   bool   `json:"forwardAgent"`
+	KeepaliveMs    uint64 `json:"keepaliveMs"`
+	// Command set ⇒ exec it per connection (dial-stdio); else each connection is forwarded to RemoteSocket.
+	Command      string `json:"command"`
+	RemoteSocket string `json:"remoteSocket"`
+}
+
+// sshTransport owns the shared SSH connection of one bridge. get() dials on first use and after a drop.
+type sshTransport struct {
+	spec      sshBridgeSpec
+	address   string
+	config    *ssh.ClientConfig
+	agent     agent.ExtendedAgent
+	agentConn net.Conn
+	// entry receives the remote dial-stdio command's stderr (the in-process analog of the child's stderr pipe)
+	// and the per-connection dial/session errors.
+	entry *bridgeEntry
+
+	mu     sync.Mutex
+	client *ssh.Client
+	closed bool
+}
+
+// startSSHBridge resolves the target, dials once up front (so a bad key / unknown host fails ensure with the real
+// reason), then serves the local socket exactly like startStdioBridge.
+func startSSHBridge(entry *bridgeEntry) error {
+	spec := entry.spec
+	if spec.SSH == nil {
+		return errors.New("ssh bridge: missing ssh settings")
+	}
+	transport, err := newSSHTransport(resolveSSHBridgeSpec(*spec.SSH))
+	if err != nil {
+		return err
+	}
+	transport.entry = entry
+	if _, err := transport.get(); err != nil {
+		transport.close()
+		return err
+	}
+	listener, err := newStdioListener(spec.LocalAddress)
+	if err != nil {
+		transport.close()
+		return err
+	}
+	entry.ssh = transport
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			go transport.serve(entry.metrics.meter(conn))
+		}
+	}()
+	entry.stop = func() {
+		_ = listener.Close()
+		transport.close()
+		removeLocalSocket(spec.LocalAddress)
+	}
+	return nil
+}
+
+// sshBridgeArgv is the `ssh` command line an in-process bridge stands in for, for the command policy to judge:
+// `ssh -p port -l user [-i identity] [-A] host -- command`, or `… host -W remoteSocket` when it forwards a socket.
+func sshBridgeArgv(spec sshBridgeSpec) []string {
+	argv := []string{"-p", strconv.Itoa(int(spec.Port)), "-l", spec.User}
+	if spec.IdentityFile != "" {
+		argv = append(argv, "-i", spec.IdentityFile)
+	}
+	if spec.ForwardAgent {
+		argv = append(argv, "-A")
+	}
+	if spec.Command != "" {
+		return append(argv, spec.Host, "--", spec.Command)
+	}
+	return append(argv, "-W", spec.RemoteSocket, spec.Host)
+}
+
+// resolveSSHBridgeSpec fills the blanks: ~/.ssh/config values for ConfigHost, then port 22 and the local user
+// name (what `ssh` itself defaults to).
+func resolveSSHBridgeSpec(spec sshBridgeSpec) sshBridgeSpec {
+	if spec.ConfigHost != "" {
+		for _, host := range (&PlatformService{}).GetSSHConfig() {
+			if host.ConfigHost != spec.ConfigHost {
+				continue
+			}
+			if spec.Host == "" {
+				spec.Host = host.HostName
+			}
+			if spec.Port == 0 {
+				spec.Port = host.Port
+			}
+			if spec.User == "" {
+				spec.User = host.User
+			}
+			if spec.IdentityFile == "" {
+				spec.IdentityFile = host.IdentityFile
+			}
+			break
+		}
+		if spec.Host == "" {
+			spec.Host = spec.ConfigHost
+		}
+	}
+	if spec.Port == 0 {
+		spec.Port = 22
+	}
+	if spec.User == "" {
+		if current, err := user.Current(); err == nil {
+			spec.User = current.Username
+		}
+	}
+	return spec
+}
+
+func newSSHTransport(spec sshBridgeSpec) (*sshTransport, error) {
+	if spec.Host == "" {
+		return nil, errors.New("ssh bridge: no host")
+	}
+	if spec.Command == "" && spec.RemoteSocket == "" {
+		return nil, errors.New("ssh bridge: neither a dial-stdio command nor a remote socket to forward")
+	}
+	transport := &sshTransport{spec: spec, address: net.JoinHostPort(spec.Host, strconv.FormatUint(uint64(spec.Port), 10))}
+	hostKeyCallback, hostKeyAlgorithms, err := sshHostKeyCallback(spec.KnownHostsFile, transport.address)
+	if err != nil {
+		return nil, err
+	}
+	transport.agent, transport.agentConn = dialSSHAgent()
+	auth, err := transport.authMethods()
+	if err != nil {
+		transport.close()
+		return nil, err
+	}
+	transport.config = &ssh.ClientConfig{
+		User:              spec.User,
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms,
+		Timeout:           sshDialTimeout,
+	}
+	return transport, nil
+}
+
+// get returns the live client, dialing a new one when there is none (first use, or after a keepalive drop).
+func (t *sshTransport) get() (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, errors.New("ssh bridge: stopped")
+	}
+	if t.client != nil {
+		return t.client, nil
+	}
+	client, err := ssh.Dial("tcp", t.address, t.config)
+	if err != nil {
+		return nil, fmt.Errorf("ssh %s@%s: %w", t.spec.User, t.address, err)
+	}
+	if t.spec.ForwardAgent && t.agent != nil {
+		_ = agent.ForwardToAgent(client, t.agent)
+	}
+	t.client = client
+	go t.keepalive(client)
+	return client, nil
+}
+
+// keepalive pings the server (keepalive@openssh.com, like ServerAliveInterval) and drops the client when a ping
+// fails or goes unanswered, so the next connection redials instead of hanging on a dead TCP session.
+func (t *sshTransport) keepalive(client *ssh.Client) {
+	interval := sshDefaultKeepalive
+	if t.spec.KeepaliveMs > 0 {
+		interval = time.Duration(t.spec.KeepaliveMs) * time.Millisecond
+	}
+	done := make(chan struct{})
+	go func() {
+		_ = client.Wait()
+		close(done)
+	}()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			t.drop(client)
+			return
+		case <-ticker.C:
+			replied := make(chan error, 1)
+			go func() {
+				_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+				replied <- err
+			}()
+			var err error
+			select {
+			case err = <-replied:
+			case <-time.After(sshKeepaliveDeadline):
+				err = errors.New("keepalive timeout")
+			}
+			if err != nil {
+				_ = client.Close()
+				t.drop(client)
+				return
+			}
+		}
+	}
+}
+
+func (t *sshTransport) drop(client *ssh.Client) {
+	t.mu.Lock()
+	if t.client == client {
+		t.client = nil
+	}
+	t.mu.Unlock()
+}
+
+func (t *sshTransport) close() {
+	t.mu.Lock()
+	t.closed = true
+	client := t.client
+	t.client = nil
+	t.mu.Unlock()
+	if client != nil {
+		_ = client.Close()
+	}
+	if t.agentConn != nil {
+		_ = t.agentConn.Close()
+	}
+}
+
+// serve relays one accepted local connection: a dial-stdio session, or a streamlocal channel to RemoteSocket.
+func (t *sshTransport) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	client, err := t.get()
+	if err != nil {
+		t.fail(err)
+		return
+	}
+	if t.spec.Command == "" {
+		remote, dialErr := client.Dial("unix", t.spec.RemoteSocket)
+		if dialErr != nil {
+			t.fail(dialErr)
+			return
+		}
+		defer func() { _ = remote.Close() }()
+		if err := shuttle(conn, remote, func() { closeWrite(remote) }, remote); err != nil {
+			t.fail(err)
+		}
+		return
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		t.fail(err)
+		return
+	}
+	defer func() { _ = session.Close() }()
+	if t.spec.ForwardAgent && t.agent != nil {
+		_ = agent.RequestAgentForwarding(session)
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		t.fail(err)
+		return
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		t.fail(err)
+		return
+	}
+	if t.entry != nil {
+		session.Stderr = t.entry.stderr
+	}
+	if err := session.Start(t.spec.Command); err != nil {
+		t.fail(err)
+		return
+	}
+	copyErr := shuttle(conn, stdin, func() { _ = stdin.Close() }, stdout)
+	// A dial-stdio command that exits non-zero (engine not installed, daemon down) is the failure worth keeping;
+	// its own stderr is already in the ring.
+	if err := session.Wait(); err != nil {
+		t.fail(fmt.Errorf("%s: %w", t.spec.Command, err))
+	} else if copyErr != nil {
+		t.fail(copyErr)
+	}
+}
+
+// fail records a per-connection error as the bridge's last error and in its stderr ring — the in-process client
+// has no child whose stderr would explain it.
+func (t *sshTransport) fail(err error) {
+	if t.entry != nil {
+		t.entry.metrics.fail(err)
+		_, _ = t.entry.stderr.Write([]byte("ssh bridge: " + err.Error() + "\n"))
+	}
+}
+
+// authMethods offers the agent's keys first (what `ssh` does), then the identity file — or, without one, the
+// default ~/.ssh/id_* keys. A passphrase-protected key is skipped (it can only be used through the agent).
+func (t *sshTransport) authMethods() ([]ssh.AuthMethod, error) {
+	methods := []ssh.AuthMethod{}
+	if t.agent != nil {
+		methods = append(methods, ssh.PublicKeysCallback(t.agent.Signers))
+	}
+	candidates := []string{}
+	if t.spec.IdentityFile != "" {
+		candidates = append(candidates, expandHomePath(t.spec.IdentityFile))
+	} else if home, err := os.UserHomeDir(); err == nil {
+		for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+			candidates = append(candidates, filepath.Join(home, ".ssh", name))
+		}
+	}
+	signers := []ssh.Signer{}
+	var keyErr error
+	for _, path := range candidates {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			if t.spec.IdentityFile != "" {
+				keyErr = fmt.Errorf("ssh identity %s: %w", path, err)
+			}
+			continue
+		}
+		signer, err := ssh.ParsePrivateKey(contents)
+		if err != nil {
+			var missing *ssh.PassphraseMissingError
+			if !errors.As(err, &missing) || t.agent == nil {
+				keyErr = fmt.Errorf("ssh identity %s: %w", path, err)
+			}
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+	if len(methods) == 0 {
+		if keyErr != nil {
+			return nil, keyErr
+		}
+		return nil, errors.New("ssh bridge: no usable key (no ssh-agent and no identity file)")
+	}
+	return methods, nil
+}
+
+// dialSSHAgent connects to the running agent ($SSH_AUTH_SOCK; the OpenSSH agent pipe on Windows). The connection
+// stays open for the bridge's lifetime — it backs both key signing and agent forwarding.
+func dialSSHAgent() (agent.ExtendedAgent, net.Conn) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" && runtime.GOOS == "windows" {
+		socket = "//./pipe/openssh-ssh-agent"
+	}
+	if socket == "" {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	conn, err := dialLocalTransport(ctx, socket)
+	if err != nil {
+		return nil, nil
+	}
+	return agent.NewClient(conn), conn
+}
+
+// sshHostKeyCallback verifies against known_hosts — the given file, else ~/.ssh/known_hosts plus the system
+// ssh_known_hosts — and returns the host-key algorithms to negotiate for address. There is deliberately no
+// "accept any key" mode: an unknown host must be trusted with `ssh` first, and the error says so.
+func sshHostKeyCallback(knownHostsFile, address string) (ssh.HostKeyCallback, []string, error) {
+	files := []string{}
+	if knownHostsFile != "" {
+		files = append(files, expandHomePath(knownHostsFile))
+	} else {
+		if home, err := os.UserHomeDir(); err == nil && fileExists(filepath.Join(home, ".ssh", "known_hosts")) {
+			files = append(files, filepath.Join(home, ".ssh", "known_hosts"))
+		}
+		if fileExists("/etc/ssh/ssh_known_hosts") {
+			files = append(files, "/etc/ssh/ssh_known_hosts")
+		}
+	}
+	if len(files) == 0 {
+		return nil, nil, errors.New("ssh bridge: no known_hosts file — connect once with ssh to verify the host key")
+	}
+	callback, err := knownhosts.New(files...)
+	if err != nil {
+		return nil, nil, err
+	}
+	verify := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			if len(keyErr.Want) == 0 {
+				return fmt.Errorf("host key for %s is not in known_hosts (%s %s) — verify it with ssh first", hostname, key.Type(), ssh.FingerprintSHA256(key))
+			}
+			return fmt.Errorf("HOST KEY MISMATCH for %s: got %s %s, known_hosts has %s:%d", hostname, key.Type(), ssh.FingerprintSHA256(key), keyErr.Want[0].Filename, keyErr.Want[0].Line)
+		}
+		return err
+	}
+	return verify, knownHostKeyAlgorithms(callback, address), nil
+}
+
+// knownHostKeyAlgorithms asks known_hosts which key types it holds for the address (the callback's KeyError lists
+// them when probed with a throwaway key), so the handshake negotiates a type we can verify instead of failing
+// with a false mismatch when the server prefers another. nil ⇒ the library defaults.
+func knownHostKeyAlgorithms(callback ssh.HostKeyCallback, address string) []string {
+	_, throwaway, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil
+	}
+	probe, err := ssh.NewPublicKey(throwaway.Public())
+	if err != nil {
+		return nil
+	}
+	remote, _ := net.ResolveTCPAddr("tcp", address)
+	if remote == nil {
+		remote = &net.TCPAddr{}
+	}
+	var keyErr *knownhosts.KeyError
+	if !errors.As(callback(address, remote, probe), &keyErr) {
+		return nil
+	}
+	algorithms := []string{}
+	for _, known := range keyErr.Want {
+		switch known.Key.Type() {
+		case ssh.KeyAlgoRSA:
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			algorithms = append(algorithms, known.Key.Type())
+		}
+	}
+	if len(algorithms) == 0 {
+		return nil
+	}
+	return algorithms
+}
+
+// expandHomePath expands a leading ~/ (ssh_config and SSHHost.IdentityFile use it).
+func expandHomePath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return path
+}
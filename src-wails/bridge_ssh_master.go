@@ -0,0 +1,304 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SSH ControlMaster multiplexing for stdio bridges. Without it, every connection the proxy opens runs a fresh
+// `ssh … -- docker system dial-stdio` and pays a full SSH handshake. Instead, ensure starts one long-lived
+// `ssh -N` master per host with a ControlPath under userData. Each per-connection child is then rewritten to
+// ride that master (-oControlMaster=no -oControlPath=…). If the master socket is gone, ssh falls back to a direct
+// connection, so a dead master slows the bridge down but never breaks it.
+//
+// Masters are refcounted by ControlPath. Two bridges to the same host (same options and target) share one, and
+// bridgeManager.stop tears it down (`ssh -O exit`, then a kill) when the last bridge using it stops. Windows
+// OpenSSH has no ControlMaster support, so there the argv is left untouched.
+
+const (
+	sshMasterReadyAttempts = 100
+	sshMasterReadyInterval = 100 * time.Millisecond
+	// sshMasterReviveInterval rate-limits relaunching a master that died mid-life (checked per accepted connection).
+	sshMasterReviveInterval = 5 * time.Second
+	// maxSocketPathLength keeps the sockets we bind under userData (ControlPaths, published connections) within
+	// the sun_path limit (104 on macOS, 108 on Linux), with room for the random suffix ssh appends while it binds.
+	maxSocketPathLength = 90
+)
+
+// sshOptionsWithArgument are the ssh(1) flags that consume a value (attached or as the next token).
+const sshOptionsWithArgument = "BbcDEeFIiJLlmOoPpQRSWw"
+
+type sshMaster struct {
+	manager     *bridgeManager
+	controlPath string
+	launcher    string
+	options     []string
+	target      string
+	stderr      *bridgeStderr
+	refs        int
+	// ready closes once the first launch has settled, launchErr holding its failure. Bridges that share the master
+	// wait on it outside m.mu.
+	ready     chan struct{}
+	launchErr error
+
+	mu         sync.Mutex
+	process    *os.Process
+	exited     chan struct{}
+	lastLaunch time.Time
+	closed     bool
+}
+
+// multiplexSSH returns the argv each connection of a stdio bridge spawns with. For an ssh launcher this is the
+// spec's argv rewritten to ride a shared master (started here on first use). Otherwise, or when the master cannot
+// be started, it is the spec's argv unchanged. Called without m.mu: the master is registered under it, but its
+// launch (up to the readiness wait) runs outside, and a second bridge for the same host waits for that launch.
+func (m *bridgeManager) multiplexSSH(entry *bridgeEntry) []string {
+	spec := entry.spec
+	if runtime.GOOS == "windows" || !isSSHLauncher(spec.Launcher) || hasControlOption(spec.Argv) {
+		return spec.Argv
+	}
+	options, target, tail, ok := splitSSHArgv(spec.Argv)
+	if !ok {
+		return spec.Argv
+	}
+	controlPath, err := sshControlPath(options, target)
+	if err != nil {
+		return spec.Argv
+	}
+	for {
+		m.mu.Lock()
+		if m.masters == nil {
+			m.masters = map[string]*sshMaster{}
+		}
+		master, shared := m.masters[controlPath]
+		if !shared {
+			master = &sshMaster{manager: m, controlPath: controlPath, launcher: spec.Launcher, options: options, target: target, stderr: entry.stderr, ready: make(chan struct{})}
+			m.masters[controlPath] = master
+		}
+		m.mu.Unlock()
+		if shared {
+			<-master.ready
+		} else {
+			master.launchErr = master.launch()
+			if master.launchErr != nil {
+				m.mu.Lock()
+				if m.masters[controlPath] == master {
+					delete(m.masters, controlPath)
+				}
+				m.mu.Unlock()
+			}
+			close(master.ready)
+		}
+		if master.launchErr != nil {
+			_, _ = entry.stderr.Write([]byte("ssh master: " + master.launchErr.Error() + "; connecting without multiplexing\n"))
+			return spec.Argv
+		}
+		m.mu.Lock()
+		if m.masters[controlPath] != master {
+			// Its last bridge stopped (and closed it) while this one waited: start over with a fresh master.
+			m.mu.Unlock()
+			continue
+		}
+		master.refs++
+		entry.master = master
+		m.mu.Unlock()
+		break
+	}
+	argv := append([]string{}, options...)
+	argv = append(argv, "-oControlMaster=no", "-oControlPath="+controlPath, target)
+	return append(argv, tail...)
+}
+
+// releaseSSHMaster drops the entry's reference and closes the master once no bridge uses it.
+func (m *bridgeManager) releaseSSHMaster(entry *bridgeEntry) {
+	m.mu.Lock()
+	master := m.unrefSSHMasterLocked(entry)
+	m.mu.Unlock()
+	if master != nil {
+		master.close()
+	}
+}
+
+// unrefSSHMasterLocked drops the entry's reference (m.mu held) and returns the master when it was the last one,
+// for the caller to close outside the lock.
+func (m *bridgeManager) unrefSSHMasterLocked(entry *bridgeEntry) *sshMaster {
+	master := entry.master
+	if master == nil {
+		return nil
+	}
+	entry.master = nil
+	master.refs--
+	if master.refs > 0 {
+		return nil
+	}
+	if m.masters[master.controlPath] == master {
+		delete(m.masters, master.controlPath)
+	}
+	return master
+}
+
+// launch spawns `ssh <options> -oControlMaster=yes -oControlPath=… -N <target>` and waits for the control
+// socket to appear, failing fast if ssh exits first (auth or host error, already in the stderr ring). The master
+// is a launch of its own for the command policy, authorized (and audited) each time it is started; a refused
+// one leaves its bridges connecting without multiplexing.
+func (s *sshMaster) launch() error {
+	argv := append([]string{}, s.options...)
+	argv = append(argv, "-oControlMaster=yes", "-oControlPath="+s.controlPath, "-oControlPersist=no", "-N", s.target)
+	if err := launchPolicy.authorize(commandRequest{"ssh-master", s.launcher, argv, "", ""}); err != nil {
+		return err
+	}
+	_ = os.Remove(s.controlPath)
+	cmd := exec.Command(s.launcher, argv...)
+	configureHiddenWindow(cmd)
+	cmd.Stderr = s.stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+	s.mu.Lock()
+	if s.closed {
+		// close ran while this (re)launch was starting; it could not see this child, so it is ours to kill.
+		s.mu.Unlock()
+		_ = cmd.Process.Kill()
+		<-exited
+		_ = os.Remove(s.controlPath)
+		return errors.New("ssh master closed")
+	}
+	s.process = cmd.Process
+	s.exited = exited
+	s.lastLaunch = time.Now()
+	s.mu.Unlock()
+	for range sshMasterReadyAttempts {
+		if fileExists(s.controlPath) {
+			return nil
+		}
+		select {
+		case <-exited:
+			return errors.New("ssh exited before the control socket was ready")
+		case <-time.After(sshMasterReadyInterval):
+		}
+	}
+	_ = cmd.Process.Kill()
+	return errors.New("control socket did not appear")
+}
+
+// revive relaunches a master that exited on its own (network drop, sleep), at most once per revive interval and
+// in the background — the connection that noticed goes direct meanwhile.
+func (s *sshMaster) revive() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.exited == nil || time.Since(s.lastLaunch) < sshMasterReviveInterval {
+		return
+	}
+	select {
+	case <-s.exited:
+	default:
+		return
+	}
+	s.lastLaunch = time.Now()
+	s.exited = nil
+	go func() {
+		if s.launch() == nil {
+			s.manager.persistState() // the revived master has a new PID
+		}
+	}()
+}
+
+// pid is the current master's PID (0 once closed).
+func (s *sshMaster) pid() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.process == nil || s.closed {
+		return 0
+	}
+	return s.process.Pid
+}
+
+// close asks the master to exit over its control socket, kills it if it is still around, and unlinks the socket.
+func (s *sshMaster) close() {
+	exit := exec.Command(s.launcher, append(append([]string{}, s.options...), "-oControlPath="+s.controlPath, "-O", "exit", s.target)...)
+	configureHiddenWindow(exit)
+	_ = exit.Run()
+	s.mu.Lock()
+	process, exited := s.process, s.exited
+	s.exited = nil
+	s.closed = true
+	s.mu.Unlock()
+	if process != nil {
+		_ = process.Kill()
+	}
+	if exited != nil {
+		<-exited
+	}
+	_ = os.Remove(s.controlPath)
+}
+
+func isSSHLauncher(launcher string) bool {
+	return strings.TrimSuffix(filepath.Base(launcher), ".exe") == "ssh"
+}
+
+// hasControlOption reports an argv that already configures multiplexing itself — left alone.
+func hasControlOption(argv []string) bool {
+	for _, arg := range argv {
+		lower := strings.ToLower(arg)
+		if strings.Contains(lower, "controlmaster") || strings.Contains(lower, "controlpath") || arg == "-S" {
+			return true
+		}
+	}
+	return false
+}
+
+// splitSSHArgv splits an ssh argv into its options, the destination, and everything after it (the optional "--"
+// and the remote command). Option clusters like -NL are walked letter by letter.
+func splitSSHArgv(argv []string) (options []string, target string, tail []string, ok bool) {
+	for index := 0; index < len(argv); index++ {
+		arg := argv[index]
+		if arg == "--" {
+			return nil, "", nil, false // `ssh [options] -- destination`: too unusual to rewrite safely
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return argv[:index], arg, argv[index+1:], true
+		}
+		for position := 1; position < len(arg); position++ {
+			if strings.IndexByte(sshOptionsWithArgument, arg[position]) < 0 {
+				continue
+			}
+			if position == len(arg)-1 {
+				index++ // the value is the next token
+			}
+			break
+		}
+	}
+	return nil, "", nil, false
+}
+
+// sshControlPath is userData/ssh/cm-<hash of options + target>: one master per distinct host configuration, with
+// a short name so the socket path fits sun_path.
+func sshControlPath(options []string, target string) (string, error) {
+	base, err := userDataPath()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(base, "ssh")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(strings.Join(append(append([]string{}, options...), target), "\x00")))
+	path := filepath.Join(dir, "cm-"+hex.EncodeToString(sum[:8]))
+	if len(path) > maxSocketPathLength {
+		return "", errors.New("control path too long: " + path)
+	}
+	return path, nil
+}
@@ -0,0 +1,163 @@
+//go:build !windows
+
+package main
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSSHScript stands in for ssh(1): the master (-oControlMaster=yes) creates its ControlPath and idles, `-O exit`
+// succeeds, and any other invocation is a dial-stdio child (cat). Every argv is logged, one line per call.
+const fakeSSHScript = `#!/bin/sh
+echo "$*" >> "$FAKE_SSH_LOG"
+case "$*" in
+*ControlMaster=yes*)
+	for arg; do case "$arg" in -oControlPath=*) path="${arg#-oControlPath=}";; esac; done
+	: > "$path"
+	exec sleep 60;;
+*"-O exit"*) exit 0;;
+*) exec cat;;
+esac
+`
+
+func TestStdioBridgeRidesSSHMaster(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CONTAINER_DESKTOP_USER_DATA_DIR", dir)
+	log := filepath.Join(dir, "ssh.log")
+	t.Setenv("FAKE_SSH_LOG", log)
+	launcher := filepath.Join(t.TempDir(), "ssh")
+	if err := os.WriteFile(launcher, []byte(fakeSSHScript), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	manager := &bridgeManager{}
+	argv := []string{"-oBatchMode=yes", "-p", "2222", "me@remote", "--", "docker", "system", "dial-stdio"}
+	first := bridgeSpec{Kind: "stdio", Key: "a", LocalAddress: filepath.Join(dir, "a.sock"), Launcher: launcher, Argv: argv}
+	second := bridgeSpec{Kind: "stdio", Key: "b", LocalAddress: filepath.Join(dir, "b.sock"), Launcher: launcher, Argv: argv}
+	for _, spec := range []bridgeSpec{first, second} {
+		if _, err := manager.ensure(spec); err != nil {
+			t.Fatalf("ensure %s: %v", spec.Key, err)
+		}
+	}
+	if len(manager.masters) != 1 {
+		t.Fatalf("masters = %d, want one shared by both bridges", len(manager.masters))
+	}
+	var controlPath string
+	for path := range manager.masters {
+		controlPath = path
+	}
+
+	conn, err := net.Dial("unix", first.LocalAddress)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	_, _ = conn.Write([]byte("multiplexed"))
+	_ = conn.(*net.UnixConn).CloseWrite()
+	if echoed, _ := io.ReadAll(conn); string(echoed) != "multiplexed" {
+		t.Fatalf("echoed = %q", echoed)
+	}
+	_ = conn.Close()
+
+	manager.stop("a")
+	if !fileExists(controlPath) {
+		t.Fatal("master closed while bridge b still uses it")
+	}
+	manager.stop("b")
+	if fileExists(controlPath) {
+		t.Fatal("control socket left behind after the last bridge stopped")
+	}
+
+	raw, _ := os.ReadFile(log)
+	calls := strings.Split(strings.TrimSpace(string(raw)), "\n")
+	want := []string{
+		"-oBatchMode=yes -p 2222 -oControlMaster=yes -oControlPath=" + controlPath + " -oControlPersist=no -N me@remote",
+		"-oBatchMode=yes -p 2222 -oControlMaster=no -oControlPath=" + controlPath + " me@remote -- docker system dial-stdio",
+		"-oBatchMode=yes -p 2222 -oControlPath=" + controlPath + " -O exit me@remote",
+	}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("ssh calls =\n%s\nwant\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// A slow master starts outside m.mu: other bridges stay available, and a second bridge to the same host waits for
+// the first launch and shares it.
+func TestSSHMasterLaunchesOutsideTheManagerLock(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CONTAINER_DESKTOP_USER_DATA_DIR", dir)
+	t.Setenv("FAKE_SSH_LOG", filepath.Join(dir, "ssh.log"))
+	launcher := filepath.Join(t.TempDir(), "ssh")
+	slowScript := strings.Replace(fakeSSHScript, `: > "$path"`, `sleep 0.6; : > "$path"`, 1)
+	if err := os.WriteFile(launcher, []byte(slowScript), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	manager := &bridgeManager{}
+	argv := []string{"me@remote", "--", "docker", "system", "dial-stdio"}
+	errs := make(chan error, 2)
+	for _, key := range []string{"a", "b"} {
+		go func() {
+			_, err := manager.ensure(bridgeSpec{Kind: "stdio", Key: key, LocalAddress: filepath.Join(dir, key+".sock"), Launcher: launcher, Argv: argv})
+			errs <- err
+		}()
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	started := time.Now()
+	manager.list()
+	if _, err := manager.ensure(bridgeSpec{Kind: "stdio", Key: "other", LocalAddress: filepath.Join(dir, "other.sock"), Launcher: "cat"}); err != nil {
+		t.Fatalf("ensure other: %v", err)
+	}
+	defer manager.stop("other")
+	if elapsed := time.Since(started); elapsed > 300*time.Millisecond {
+		t.Errorf("other bridge operations waited %v on the starting master", elapsed)
+	}
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Fatalf("ensure: %v", err)
+		}
+	}
+	defer manager.stop("a")
+	defer manager.stop("b")
+	if len(manager.masters) != 1 {
+		t.Fatalf("masters = %d, want one shared by both bridges", len(manager.masters))
+	}
+	for _, master := range manager.masters {
+		if master.refs != 2 {
+			t.Errorf("master refs = %d, want 2", master.refs)
+		}
+	}
+}
+
+// A master closed while its launch was starting kills the child it started instead of leaving it orphaned.
+func TestSSHMasterLaunchAfterClose(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "survived")
+	launcher := filepath.Join(dir, "ssh")
+	if err := os.WriteFile(launcher, []byte("#!/bin/sh\nsleep 0.3\ntouch "+marker+"\nexec sleep 60\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	master := &sshMaster{controlPath: filepath.Join(dir, "cm"), launcher: launcher, target: "me@remote", stderr: newBridgeStderr("closed")}
+	master.closed = true // as close leaves it
+	if err := master.launch(); err == nil {
+		t.Fatal("launch succeeded on a closed master")
+	}
+	time.Sleep(500 * time.Millisecond)
+	if fileExists(marker) {
+		t.Error("the master child outlived its closed master")
+	}
+}
+
+func TestSplitSSHArgv(t *testing.T) {
+	options, target, tail, ok := splitSSHArgv([]string{"-NL", "/a:/b", "-i", "key", "-oX=y", "host", "--", "cmd"})
+	if !ok || target != "host" || !slices.Equal(options, []string{"-NL", "/a:/b", "-i", "key", "-oX=y"}) || !slices.Equal(tail, []string{"--", "cmd"}) {
+		t.Fatalf("split = %v %q %v %v", options, target, tail, ok)
+	}
+	if _, _, _, ok := splitSSHArgv([]string{"-p", "22"}); ok {
+		t.Fatal("argv without a destination split")
+	}
+}
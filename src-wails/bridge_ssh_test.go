@@ -0,0 +1,303 @@
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testSSHServer is an in-process SSH server: "exec" sessions echo stdin back (cat standing in for dial-stdio,
+// as in TestStdioBridgeRoundTrip) — except "missing-engine", which fails like a remote without the CLI — and direct-streamlocal / direct-tcpip channels are relayed to the named local
+// unix socket / TCP address.
+type testSSHServer struct {
+	address  string
+	hostKey  ssh.PublicKey
+	identity string
+	execs    chan string
+}
+
+func startTestSSHServer(t *testing.T) *testSSHServer {
+	t.Helper()
+	dir := t.TempDir()
+	_, hostPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("host key: %v", err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostPrivate)
+	if err != nil {
+		t.Fatalf("host signer: %v", err)
+	}
+	clientPublic, clientPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("client key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(clientPrivate, "")
+	if err != nil {
+		t.Fatalf("marshal client key: %v", err)
+	}
+	identity := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(identity, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("write identity: %v", err)
+	}
+	authorized, err := ssh.NewPublicKey(clientPublic)
+	if err != nil {
+		t.Fatalf("authorized key: %v", err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return &ssh.Permissions{}, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	config.AddHostKey(hostSigner)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen tcp: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	server := &testSSHServer{address: listener.Addr().String(), hostKey: hostSigner.PublicKey(), identity: identity, execs: make(chan string, 8)}
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			go server.handle(conn, config)
+		}
+	}()
+	return server
+}
+
+func (s *testSSHServer) handle(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		switch newChannel.ChannelType() {
+		case "session":
+			channel, channelRequests, acceptErr := newChannel.Accept()
+			if acceptErr != nil {
+				continue
+			}
+			go s.session(channel, channelRequests)
+		case "direct-streamlocal@openssh.com":
+			var target struct {
+				SocketPath string
+				Reserved0  string
+				Reserved1  uint32
+			}
+			if ssh.Unmarshal(newChannel.ExtraData(), &target) != nil {
+				_ = newChannel.Reject(ssh.ConnectionFailed, "bad payload")
+				continue
+			}
+			relayChannel(newChannel, "unix", target.SocketPath)
+		case "direct-tcpip":
+			var target struct {
+				Host       string
+				Port       uint32
+				OriginHost string
+				OriginPort uint32
+			}
+			if ssh.Unmarshal(newChannel.ExtraData(), &target) != nil {
+				_ = newChannel.Reject(ssh.ConnectionFailed, "bad payload")
+				continue
+			}
+			relayChannel(newChannel, "tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+		default:
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+		}
+	}
+}
+
+// relayChannel dials the channel's local target and shuttles bytes both ways (rejecting when the dial fails).
+func relayChannel(newChannel ssh.NewChannel, network, address string) {
+	local, err := net.Dial(network, address)
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, channelRequests, err := newChannel.Accept()
+	if err != nil {
+		_ = local.Close()
+		return
+	}
+	go ssh.DiscardRequests(channelRequests)
+	go func() {
+		defer func() { _ = channel.Close(); _ = local.Close() }()
+		shuttle(local, channel, func() { _ = channel.CloseWrite() }, channel)
+	}()
+}
+
+func (s *testSSHServer) session(channel ssh.Channel, requests <-chan *ssh.Request) {
+	for request := range requests {
+		if request.Type != "exec" {
+			_ = request.Reply(false, nil)
+			continue
+		}
+		var exec struct{ Command string }
+		_ = ssh.Unmarshal(request.Payload, &exec)
+		s.execs <- exec.Command
+		_ = request.Reply(true, nil)
+		if exec.Command == "missing-engine" {
+			_, _ = channel.Stderr().Write([]byte("sh: missing-engine: not found\n"))
+			_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{127}))
+			_ = channel.Close()
+			continue
+		}
+		go func() {
+			_, _ = io.Copy(channel, channel)
+			_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+			_ = channel.Close()
+		}()
+	}
+}
+
+func (s *testSSHServer) spec(t *testing.T, knownHostsKey ssh.PublicKey) sshBridgeSpec {
+	t.Helper()
+	host, port, _ := net.SplitHostPort(s.address)
+	portNumber, _ := strconv.Atoi(port)
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(knownHosts, []byte(knownhosts.Line([]string{knownhosts.Normalize(s.address)}, knownHostsKey)+"\n"), 0o600); err != nil {
+		t.Fatalf("write known_hosts: %v", err)
+	}
+	return sshBridgeSpec{Host: host, Port: uint32(portNumber), User: "tester", IdentityFile: s.identity, KnownHostsFile: knownHosts}
+}
+
+func roundTrip(t *testing.T, socket, message string) string {
+	t.Helper()
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("dial bridge: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err := conn.Write([]byte(message)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	_ = conn.(*net.UnixConn).CloseWrite()
+	echoed, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return string(echoed)
+}
+
+// The in-process SSH bridge execs the dial-stdio command per connection and relays raw bytes both ways.
+func TestSSHBridgeDialStdio(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	server := startTestSSHServer(t)
+	sshSpec := server.spec(t, server.hostKey)
+	sshSpec.Command = "docker system dial-stdio"
+	manager := &bridgeManager{}
+	socket := filepath.Join(t.TempDir(), "ssh-bridge.sock")
+	if _, err := manager.ensure(bridgeSpec{Kind: "ssh", Key: "remote", LocalAddress: socket, SSH: &sshSpec}); err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+	defer manager.stop("remote")
+
+	for _, message := range []string{"first request", "second request"} {
+		if got := roundTrip(t, socket, message); got != message {
+			t.Fatalf("echoed = %q, want %q", got, message)
+		}
+		if command := <-server.execs; command != "docker system dial-stdio" {
+			t.Fatalf("exec = %q", command)
+		}
+	}
+}
+
+// A dial-stdio command that fails remotely becomes the bridge's last error and lands in its stderr ring, beside
+// the command's own stderr.
+func TestSSHBridgeRecordsSessionFailures(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	server := startTestSSHServer(t)
+	sshSpec := server.spec(t, server.hostKey)
+	sshSpec.Command = "missing-engine"
+	manager := &bridgeManager{}
+	socket := filepath.Join(t.TempDir(), "ssh-failing.sock")
+	if _, err := manager.ensure(bridgeSpec{Kind: "ssh", Key: "failing", LocalAddress: socket, SSH: &sshSpec}); err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+	defer manager.stop("failing")
+	roundTrip(t, socket, "request")
+	waitFor(t, "the session failure", func() bool {
+		return strings.Contains(manager.stderrOutput("failing"), "ssh bridge: missing-engine: Process exited with status 127")
+	})
+	manager.mu.Lock()
+	lastErr := manager.entries["failing"].metrics.lastErr()
+	manager.mu.Unlock()
+	if !strings.Contains(lastErr, "status 127") || !strings.Contains(manager.stderrOutput("failing"), "missing-engine: not found") {
+		t.Errorf("last error %q, stderr %q", lastErr, manager.stderrOutput("failing"))
+	}
+}
+
+// With no Command, each connection is forwarded to RemoteSocket over a direct-streamlocal channel.
+func TestSSHBridgeForwardsRemoteSocket(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	server := startTestSSHServer(t)
+	remoteSocket := filepath.Join(t.TempDir(), "remote-engine.sock")
+	remote, err := net.Listen("unix", remoteSocket)
+	if err != nil {
+		t.Fatalf("listen remote: %v", err)
+	}
+	defer func() { _ = remote.Close() }()
+	go func() {
+		for {
+			conn, acceptErr := remote.Accept()
+			if acceptErr != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				data, _ := io.ReadAll(conn)
+				_, _ = conn.Write([]byte(strings.ToUpper(string(data))))
+			}()
+		}
+	}()
+
+	sshSpec := server.spec(t, server.hostKey)
+	sshSpec.RemoteSocket = remoteSocket
+	manager := &bridgeManager{}
+	socket := filepath.Join(t.TempDir(), "ssh-forward.sock")
+	if _, err := manager.ensure(bridgeSpec{Kind: "ssh", Key: "forward", LocalAddress: socket, SSH: &sshSpec}); err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+	defer manager.stop("forward")
+	if got := roundTrip(t, socket, "ping"); got != "PING" {
+		t.Fatalf("forwarded reply = %q, want PING", got)
+	}
+}
+
+// A host key that does not match known_hosts fails ensure with a host-key error (no silent trust).
+func TestSSHBridgeRejectsUnknownHostKey(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	server := startTestSSHServer(t)
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	otherKey, _ := ssh.NewPublicKey(otherPublic)
+	sshSpec := server.spec(t, otherKey)
+	sshSpec.Command = "docker system dial-stdio"
+	manager := &bridgeManager{}
+	_, err = manager.ensure(bridgeSpec{Kind: "ssh", Key: "mitm", LocalAddress: filepath.Join(t.TempDir(), "mitm.sock"), SSH: &sshSpec})
+	if err == nil || !strings.Contains(err.Error(), "HOST KEY MISMATCH") {
+		t.Fatalf("ensure err = %v, want a host key mismatch", err)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Crash recovery — a crash leaves the unix sockets of startStdioBridge/startTunnel at their LocalAddress and can
+// orphan the long-lived children (`ssh -NL` tunnels, ControlMasters), which hold no pipe to us and keep running.
+// The manager records what it owns in bridge-state.json under userData after every change. On the next launch,
+// recoverBridges reaps what the previous run left, before the first ensure. The sockets under userData/sockets
+// (published connections) and userData/ssh (ControlPaths) are ours by location and need no record.
+//
+// Persistence is off until recoverBridges sets statePath, so tests (and any manager but the app's) never touch
+// the real userData.
+
+const bridgeStateFile = "bridge-state.json"
+
+// bridgeState is the file format. OwnerPID (with OwnerStart, its start time) guards against reaping for an
+// instance that is still running.
+type bridgeState struct {
+	OwnerPID   int                `json:"ownerPid"`
+	OwnerStart string             `json:"ownerStart,omitempty"`
+	Sockets    []string           `json:"sockets"`
+	Processes  []bridgeStateChild `json:"processes"`
+}
+
+// bridgeStateChild is a recorded child. Before it is killed, Launcher is matched against the live process's name
+// and Start against its start time (processStartTime), so a PID the OS has since reused — even for another ssh
+// after a reboot — is left alone. A record without a start time is never killed.
+type bridgeStateChild struct {
+	PID      int    `json:"pid"`
+	Launcher string `json:"launcher"`
+	Start    string `json:"start,omitempty"`
+}
+
+// recoverBridges reaps the previous run's leftovers and turns persistence on for the shared manager. Called from
+// main before the app runs, so before any ensure.
+func recoverBridges() {
+	base, err := userDataPath()
+	if err != nil {
+		return
+	}
+	bridges.recover(filepath.Join(base, bridgeStateFile), base)
+}
+
+// recover does nothing while the recorded owner still runs: this is then a second instance about to hand over to
+// it (SingleInstance), and everything on record is live.
+func (m *bridgeManager) recover(statePath, userData string) {
+	if contents, err := os.ReadFile(statePath); err == nil {
+		var previous bridgeState
+		if json.Unmarshal(contents, &previous) == nil {
+			if previousOwnerAlive(previous.OwnerPID, previous.OwnerStart) {
+				return
+			}
+			for _, child := range previous.Processes {
+				if recordedProcessRunning(child.PID, child.Launcher, child.Start) {
+					terminateProcess(child.PID)
+				}
+			}
+			for _, socket := range previous.Sockets {
+				removeLocalSocket(socket)
+			}
+		}
+	}
+	for _, pattern := range []string{filepath.Join(userData, "sockets", "*.sock"), filepath.Join(userData, "ssh", "cm-*")} {
+		matches, _ := filepath.Glob(pattern)
+		for _, match := range matches {
+			_ = os.Remove(match)
+		}
+	}
+	m.mu.Lock()
+	m.statePath = statePath
+	m.persistStateLocked()
+	m.mu.Unlock()
+}
+
+// previousOwnerAlive reports whether the recorded owner is another instance of this app that is still running. A
+// record without the owner's start time (an older file) is judged by name alone, which errs on not reaping.
+func previousOwnerAlive(pid int, start string) bool {
+	if pid == 0 || pid == os.Getpid() {
+		return false
+	}
+	executable, err := os.Executable()
+	if err != nil || !processRunning(pid, executable) {
+		return false
+	}
+	return start == "" || processStartTime(pid) == start
+}
+
+// recordedProcessRunning reports whether a recorded child still runs: same PID, launcher and start time.
+func recordedProcessRunning(pid int, launcher, start string) bool {
+	return start != "" && processRunning(pid, launcher) && processStartTime(pid) == start
+}
+
+func (m *bridgeManager) persistState() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.persistStateLocked()
+}
+
+// persistStateLocked writes the current sockets and long-lived children (m.mu held). Best-effort, like
+// saveWindowState: a failed write must never fail a bridge.
+func (m *bridgeManager) persistStateLocked() {
+	if m.statePath == "" {
+		return
+	}
+	state := bridgeState{OwnerPID: os.Getpid(), OwnerStart: processStartTime(os.Getpid()), Sockets: []string{}, Processes: []bridgeStateChild{}}
+	for _, entry := range m.entries {
+		if runtime.GOOS != "windows" && entry.spec.LocalAddress != "" {
+			state.Sockets = append(state.Sockets, entry.spec.LocalAddress)
+		}
+		if entry.tunnel != nil {
+			if pid := entry.tunnel.pid(); pid != 0 {
+				state.Processes = append(state.Processes, bridgeStateChild{PID: pid, Launcher: entry.spec.Launcher, Start: processStartTime(pid)})
+			}
+		}
+	}
+	for _, master := range m.masters {
+		if pid := master.pid(); pid != 0 {
+			state.Processes = append(state.Processes, bridgeStateChild{PID: pid, Launcher: master.launcher, Start: processStartTime(pid)})
+		}
+	}
+	contents, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return
+	}
+	if os.MkdirAll(filepath.Dir(m.statePath), 0o755) != nil {
+		return
+	}
+	_ = os.WriteFile(m.statePath, contents, 0o644)
+}
@@ -0,0 +1,126 @@
+//go:build !windows
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// recover kills the recorded children that still run their launcher and started when recorded (leaving a PID whose
+// process name or start time does not match), unlinks the recorded and userData-owned sockets, then records the new run's bridges as they come and go.
+func TestBridgeRecoverReapsPreviousRun(t *testing.T) {
+	dir := t.TempDir()
+	startSleep := func() (*exec.Cmd, chan struct{}) {
+		cmd := exec.Command("sleep", "60")
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("start sleep: %v", err)
+		}
+		exited := make(chan struct{})
+		go func() { _ = cmd.Wait(); close(exited) }()
+		t.Cleanup(func() { _ = cmd.Process.Kill() })
+		return cmd, exited
+	}
+	orphan, orphanExited := startSleep()
+	bystander, bystanderExited := startSleep()
+	reused, reusedExited := startSleep()
+	if processStartTime(orphan.Process.Pid) == "" {
+		t.Fatal("no start time for a running process")
+	}
+
+	leftover := filepath.Join(dir, "leftover.sock")
+	published := filepath.Join(dir, "sockets", "conn.sock")
+	controlPath := filepath.Join(dir, "ssh", "cm-0123456789abcdef")
+	for _, path := range []string{leftover, published, controlPath} {
+		_ = os.MkdirAll(filepath.Dir(path), 0o700)
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	statePath := filepath.Join(dir, bridgeStateFile)
+	previous, _ := json.Marshal(bridgeState{
+		OwnerPID: 0,
+		Sockets:  []string{leftover},
+		Processes: []bridgeStateChild{
+			{PID: orphan.Process.Pid, Launcher: "/usr/bin/sleep", Start: processStartTime(orphan.Process.Pid)},
+			{PID: bystander.Process.Pid, Launcher: "ssh", Start: processStartTime(bystander.Process.Pid)},
+			{PID: reused.Process.Pid, Launcher: "/usr/bin/sleep", Start: "1"},
+		},
+	})
+	if err := os.WriteFile(statePath, previous, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	manager := &bridgeManager{}
+	manager.recover(statePath, dir)
+	select {
+	case <-orphanExited:
+	case <-time.After(3 * time.Second):
+		t.Fatal("recorded ssh child was not reaped")
+	}
+	select {
+	case <-bystanderExited:
+		t.Fatal("a PID running a different program was killed")
+	case <-reusedExited:
+		t.Fatal("a PID started after the record was killed")
+	default:
+	}
+	for _, path := range []string{leftover, published, controlPath} {
+		if fileExists(path) {
+			t.Fatalf("%s left behind", path)
+		}
+	}
+
+	readState := func() bridgeState {
+		var state bridgeState
+		contents, _ := os.ReadFile(statePath)
+		_ = json.Unmarshal(contents, &state)
+		return state
+	}
+	if state := readState(); state.OwnerPID != os.Getpid() || len(state.Sockets) != 0 || len(state.Processes) != 0 {
+		t.Fatalf("state after recover = %+v", state)
+	}
+	socket := filepath.Join(dir, "bridge.sock")
+	if _, err := manager.ensure(bridgeSpec{Kind: "stdio", Key: "k", LocalAddress: socket, Launcher: "cat"}); err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+	if state := readState(); !slices.Equal(state.Sockets, []string{socket}) {
+		t.Fatalf("state after ensure = %+v", state)
+	}
+	manager.stop("k")
+	if state := readState(); len(state.Sockets) != 0 {
+		t.Fatalf("state after stop = %+v", state)
+	}
+}
+
+// A launcher whose name is longer than the 15 characters of Linux's comm is still recognized, so a live instance
+// of an app with a long executable name is not taken for a dead one.
+func TestProcessRunningMatchesLongNames(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("no sleep")
+	}
+	launcher := filepath.Join(t.TempDir(), "container-desktop-long-name")
+	if err := os.Symlink(sleep, launcher); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(launcher, "60")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	if !processRunning(cmd.Process.Pid, launcher) {
+		t.Error("a live process with a long launcher name was reported dead")
+	}
+	if processRunning(cmd.Process.Pid, "container-desktop-other-name") {
+		t.Error("a process matched a launcher it does not run")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Bridge child stderr — every dial-stdio child, `ssh -NL` tunnel and in-process SSH session writes its stderr
+// here instead of into the void: a bounded per-bridge ring (queryable via BridgeService.Stderr, and its tail is
+// appended to the failure error), with each complete line also appended to the app log. This is what turns
+// "local forward socket did not appear" into "Permission denied (publickey)".
+
+const (
+	bridgeStderrBytes     = 16 * 1024
+	bridgeStderrTailBytes = 1024
+	bridgeStderrTailLines = 6
+)
+
+type bridgeStderr struct {
+	key  string
+	ring *ringBuffer
+
+	mu      sync.Mutex
+	partial []byte
+}
+
+func newBridgeStderr(key string) *bridgeStderr {
+	return &bridgeStderr{key: key, ring: newRingBuffer(bridgeStderrBytes)}
+}
+
+// Write records the bytes and logs each completed line (a partial line waits for its newline, capped so a
+// newline-free flood cannot grow it).
+func (b *bridgeStderr) Write(p []byte) (int, error) {
+	_, _ = b.ring.Write(p)
+	b.mu.Lock()
+	b.partial = append(b.partial, p...)
+	lines := []string{}
+	for {
+		index := bytes.IndexByte(b.partial, '\n')
+		if index < 0 {
+			break
+		}
+		lines = append(lines, strings.TrimRight(string(b.partial[:index]), "\r"))
+		b.partial = b.partial[index+1:]
+	}
+	if len(b.partial) > bridgeStderrTailBytes {
+		lines = append(lines, string(b.partial))
+		b.partial = nil
+	}
+	b.mu.Unlock()
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			appendLogLine("WARN", "[bridge:"+b.key+"] "+line)
+		}
+	}
+	return len(p), nil
+}
+
+// String returns everything retained.
+func (b *bridgeStderr) String() string {
+	return string(b.ring.Bytes())
+}
+
+// tail returns the last few non-empty lines (bounded in bytes) — the part worth putting in an error message.
+func (b *bridgeStderr) tail() string {
+	data := b.ring.Bytes()
+	if len(data) > bridgeStderrTailBytes {
+		data = data[len(data)-bridgeStderrTailBytes:]
+	}
+	lines := []string{}
+	for line := range strings.SplitSeq(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > bridgeStderrTailLines {
+		lines = lines[len(lines)-bridgeStderrTailLines:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// withTail appends the stderr tail to a bridge failure (unchanged when the child printed nothing).
+func (b *bridgeStderr) withTail(err error) error {
+	if err == nil || b == nil {
+		return err
+	}
+	if tail := b.tail(); tail != "" {
+		return fmt.Errorf("%w: %s", err, tail)
+	}
+	return err
+}
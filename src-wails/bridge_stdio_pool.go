@@ -0,0 +1,283 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Pre-spawned dial-stdio children. On a slow link, spawning `launcher argv` (an SSH handshake unless a
+// ControlMaster is up) dominates the latency of the first request after idle. With a pool, a stdio bridge keeps a
+// few children already spawned and connected, an accepted connection takes one immediately, and the pool refills
+// in the background. Ready children are capped at Size, and one older than MaxLifetimeMs is retired, so stale SSH
+// sessions don't pile up. A child that died while waiting (dropped session) is discarded on take.
+//
+// A launcher that cannot start, or whose children die before use (auth failure, host down), must not turn the
+// pool into a respawn loop: after such a failure refills are held back, doubling from stdioPoolRetryMin up to
+// stdioPoolRetryMax, until a child lives out its lifetime or answers a connection. The lifetime and reap interval
+// have floors for the same reason.
+
+const (
+	stdioPoolMaxSize            = 8
+	stdioPoolDefaultMaxLifetime = 5 * time.Minute
+	stdioPoolMinLifetime        = time.Second
+	stdioPoolMinReapInterval    = 500 * time.Millisecond
+	stdioPoolMaxReapInterval    = 30 * time.Second
+	stdioPoolRetryMin           = time.Second
+	stdioPoolRetryMax           = time.Minute
+)
+
+// stdioPoolSpec mirrors the `pool` object of src/platform/wails/exec/proxy-request.ts BridgeSpec (kind "stdio").
+// Size is the idle cap (children kept ready, at most stdioPoolMaxSize); MaxLifetimeMs bounds how long a ready child
+// may wait for a connection (0 = 5 minutes, at least 1 second).
+type stdioPoolSpec struct {
+	Size          int `json:"size"`
+	MaxLifetimeMs int `json:"maxLifetimeMs,omitempty"`
+}
+
+// stdioChild is one spawned `launcher argv`. Its stdio are plain os.Pipes (not StdinPipe/StdoutPipe) because the
+// exit watcher calls Wait while the child is still unused, and Wait must not close a pipe we have yet to read.
+type stdioChild struct {
+	cmd    *exec.Cmd
+	stdin  *os.File
+	stdout *os.File
+	born   time.Time
+	exited chan struct{}
+}
+
+func spawnStdioChild(launcher string, argv []string, stderr *bridgeStderr) (*stdioChild, error) {
+	stdinReader, stdinWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		_ = stdinReader.Close()
+		_ = stdinWriter.Close()
+		return nil, err
+	}
+	cmd := exec.Command(launcher, argv...)
+	configureHiddenWindow(cmd)
+	cmd.Stdin = stdinReader
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderr
+	err = cmd.Start()
+	// The child holds its own copies; closing ours is what lets stdout reach EOF when it exits.
+	_ = stdinReader.Close()
+	_ = stdoutWriter.Close()
+	if err != nil {
+		_ = stdinWriter.Close()
+		_ = stdoutReader.Close()
+		return nil, err
+	}
+	child := &stdioChild{cmd: cmd, stdin: stdinWriter, stdout: stdoutReader, born: time.Now(), exited: make(chan struct{})}
+	go func() {
+		_ = cmd.Wait()
+		close(child.exited)
+	}()
+	return child, nil
+}
+
+func (c *stdioChild) alive() bool {
+	select {
+	case <-c.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// kill ends the child and releases both pipe ends.
+func (c *stdioChild) kill() {
+	_ = c.cmd.Process.Kill()
+	<-c.exited
+	_ = c.stdin.Close()
+	_ = c.stdout.Close()
+}
+
+type stdioPool struct {
+	entry       *bridgeEntry
+	size        int
+	maxLifetime time.Duration
+
+	mu      sync.Mutex
+	ready   []*stdioChild
+	filling int
+	closed  bool
+	done    chan struct{}
+	// failures counts refill rounds that failed in a row; no refill starts before retryAt.
+	failures int
+	retryAt  time.Time
+}
+
+// newStdioPool starts the pool of a stdio bridge (nil when the spec asks for none): an initial fill, then a reaper
+// that retires expired or dead children and tops the pool back up.
+func newStdioPool(entry *bridgeEntry) *stdioPool {
+	spec := entry.spec.Pool
+	if spec == nil || spec.Size <= 0 {
+		return nil
+	}
+	pool := &stdioPool{entry: entry, size: min(spec.Size, stdioPoolMaxSize), maxLifetime: stdioPoolDefaultMaxLifetime, done: make(chan struct{})}
+	if spec.MaxLifetimeMs > 0 {
+		pool.maxLifetime = max(time.Duration(spec.MaxLifetimeMs)*time.Millisecond, stdioPoolMinLifetime)
+	}
+	pool.refill()
+	go pool.reap(min(max(pool.maxLifetime/2, stdioPoolMinReapInterval), stdioPoolMaxReapInterval))
+	return pool
+}
+
+// take hands out the oldest ready child that is still alive and within its lifetime (nil when none is ready — the
+// caller spawns one itself) and refills in the background. Safe on a nil pool.
+func (p *stdioPool) take() *stdioChild {
+	if p == nil {
+		return nil
+	}
+	var taken *stdioChild
+	stale := []*stdioChild{}
+	p.mu.Lock()
+	for len(p.ready) > 0 && taken == nil {
+		child := p.ready[0]
+		p.ready = p.ready[1:]
+		if p.usableLocked(child) {
+			taken = child
+		} else {
+			stale = append(stale, child)
+		}
+	}
+	p.mu.Unlock()
+	for _, child := range stale {
+		go child.kill()
+	}
+	p.refill()
+	return taken
+}
+
+// refill spawns children in the background until ready + in-flight reaches the idle cap. A spawn failure is
+// recorded on the bridge and retried on a later take or reap tick once the backoff allows, never in a loop.
+func (p *stdioPool) refill() {
+	p.mu.Lock()
+	missing := 0
+	if !p.closed && !time.Now().Before(p.retryAt) {
+		missing = p.size - len(p.ready) - p.filling
+		p.filling += max(missing, 0)
+	}
+	p.mu.Unlock()
+	for range missing {
+		go func() {
+			child, err := spawnStdioChild(p.entry.spec.Launcher, p.entry.argv, p.entry.stderr)
+			if err != nil {
+				p.entry.metrics.fail(err)
+			}
+			p.mu.Lock()
+			p.filling--
+			if err != nil {
+				p.failedLocked()
+			}
+			if child != nil && !p.closed {
+				p.ready = append(p.ready, child)
+				child = nil
+			}
+			p.mu.Unlock()
+			if child != nil {
+				child.kill()
+			}
+		}()
+	}
+}
+
+func (p *stdioPool) reap(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+		stale := []*stdioChild{}
+		p.mu.Lock()
+		kept := p.ready[:0]
+		for _, child := range p.ready {
+			if p.usableLocked(child) {
+				kept = append(kept, child)
+			} else {
+				stale = append(stale, child)
+			}
+		}
+		p.ready = kept
+		p.mu.Unlock()
+		for _, child := range stale {
+			child.kill()
+		}
+		p.refill()
+	}
+}
+
+// usableLocked reports a ready child that is alive and within its lifetime (p.mu held). One that died while
+// waiting counts as a failed refill; one that lived out its lifetime shows the launcher works again. Merely being
+// alive shows nothing: a launcher that fails after a moment would reset the backoff on every take.
+func (p *stdioPool) usableLocked(child *stdioChild) bool {
+	if !child.alive() {
+		p.failedLocked()
+		return false
+	}
+	if time.Since(child.born) >= p.maxLifetime {
+		p.failures = 0
+		return false
+	}
+	return true
+}
+
+// served records that a pooled child answered a connection, which also shows the launcher works again. Safe on a
+// nil pool.
+func (p *stdioPool) served() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failures = 0
+}
+
+// failedLocked pushes the next refill back (p.mu held). Failures inside one backoff window count once, so a
+// whole round of children failing together doubles the delay a single time.
+func (p *stdioPool) failedLocked() {
+	now := time.Now()
+	if now.Before(p.retryAt) {
+		return
+	}
+	p.failures++
+	p.retryAt = now.Add(min(stdioPoolRetryMin<<min(p.failures-1, 6), stdioPoolRetryMax))
+}
+
+// close stops the reaper and kills every ready child; in-flight spawns kill themselves when they land. Safe on a
+// nil pool.
+func (p *stdioPool) close() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	ready := p.ready
+	p.ready = nil
+	p.mu.Unlock()
+	close(p.done)
+	for _, child := range ready {
+		child.kill()
+	}
+}
+
+// readyCount reports the children waiting for a connection (BridgeInfo.PooledChildren). Safe on a nil pool.
+func (p *stdioPool) readyCount() int {
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.ready)
+}
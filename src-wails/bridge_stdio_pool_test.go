@@ -0,0 +1,136 @@
+//go:build !windows
+
+package main
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A pooled stdio bridge keeps Size children ready, serves a connection from one of them, refills behind it, and
+// replaces children that outlive MaxLifetimeMs. Each spawn appends a line to a log so the test can count them.
+func TestStdioBridgePoolPrespawnsChildren(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "spawns.log")
+	spec := bridgeSpec{
+		Kind:         "stdio",
+		Key:          "pooled",
+		LocalAddress: filepath.Join(dir, "pooled.sock"),
+		Launcher:     "sh",
+		Argv:         []string{"-c", "echo spawned >> " + log + "; exec cat"},
+		Pool:         &stdioPoolSpec{Size: 2, MaxLifetimeMs: 400},
+	}
+	manager := &bridgeManager{}
+	if _, err := manager.ensure(spec); err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+	defer manager.stop("pooled")
+	spawns := func() int {
+		raw, _ := os.ReadFile(log)
+		return strings.Count(string(raw), "spawned")
+	}
+	waitFor(t, "initial fill", func() bool { return manager.list()[0].PooledChildren == 2 && spawns() == 2 })
+
+	conn, err := net.Dial("unix", spec.LocalAddress)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	_, _ = conn.Write([]byte("pooled"))
+	_ = conn.(*net.UnixConn).CloseWrite()
+	if echoed, _ := io.ReadAll(conn); string(echoed) != "pooled" {
+		t.Fatalf("echoed = %q", echoed)
+	}
+	_ = conn.Close()
+	// The connection took a ready child rather than spawning its own; the pool then spawned exactly one more.
+	waitFor(t, "refill", func() bool { return manager.list()[0].PooledChildren == 2 && spawns() == 3 })
+	// Past the lifetime the reaper retires both ready children and spawns replacements.
+	waitFor(t, "lifetime retirement", func() bool { return spawns() >= 5 })
+}
+
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Children that die before use (a launcher failing auth, say) hold refills back instead of being respawned on
+// every reap tick, even with a lifetime far below the floor.
+func TestStdioPoolBacksOffFailingChildren(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "spawns.log")
+	entry := &bridgeEntry{
+		spec:   bridgeSpec{Key: "failing", Launcher: "sh", Pool: &stdioPoolSpec{Size: 2, MaxLifetimeMs: 1}},
+		argv:   []string{"-c", "echo spawned >> " + log + "; exit 255"},
+		stderr: newBridgeStderr("failing"),
+	}
+	pool := newStdioPool(entry)
+	defer pool.close()
+	time.Sleep(2500 * time.Millisecond)
+	raw, _ := os.ReadFile(log)
+	// The first fill (2), then one retry round a second after the first dead child was reaped (2 more).
+	if spawns := strings.Count(string(raw), "spawned"); spawns > 4 {
+		t.Fatalf("spawned %d children in 2.5s, want refills held back after failures", spawns)
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if pool.failures == 0 {
+		t.Error("no failure recorded for children that died before use")
+	}
+}
+
+// Only a child that lived out its lifetime or answered a connection clears the backoff; one merely alive when
+// taken does not, or a launcher that dies a moment after starting would reset it on every take.
+func TestStdioPoolBackoffResetsOnlyOnProof(t *testing.T) {
+	entry := &bridgeEntry{spec: bridgeSpec{Key: "proof", Launcher: "sleep"}, argv: []string{"30"}, stderr: newBridgeStderr("proof")}
+	pool := &stdioPool{entry: entry, size: 1, maxLifetime: time.Minute, done: make(chan struct{})}
+	defer pool.close()
+	spawn := func() *stdioChild {
+		child, err := spawnStdioChild("sleep", []string{"30"}, entry.stderr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return child
+	}
+	failures := func() int {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		return pool.failures
+	}
+
+	pool.mu.Lock()
+	pool.failures, pool.retryAt = 3, time.Now().Add(time.Hour) // no refill behind the takes
+	pool.ready = []*stdioChild{spawn()}
+	pool.mu.Unlock()
+	child := pool.take()
+	if child == nil || failures() != 3 {
+		t.Fatalf("take of a young child: child %v, failures %d; want the child and the backoff kept", child, failures())
+	}
+	child.kill()
+	pool.served()
+	if failures() != 0 {
+		t.Errorf("failures after a served connection = %d, want 0", failures())
+	}
+
+	old := spawn()
+	old.born = time.Now().Add(-2 * time.Minute)
+	pool.mu.Lock()
+	pool.failures = 3
+	pool.ready = []*stdioChild{old}
+	pool.mu.Unlock()
+	if taken := pool.take(); taken != nil {
+		t.Fatal("took a child past its lifetime")
+	}
+	if failures() != 0 {
+		t.Errorf("failures after a child lived out its lifetime = %d, want 0", failures())
+	}
+}
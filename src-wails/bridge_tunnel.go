@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tunnel supervision — an `ssh -NL` child that dies after startup (network blip, laptop sleep, server restart)
+// is restarted with exponential backoff instead of leaving a dead entry in bridgeManager.entries. Every
+// transition is pushed to the renderer on "bridge://state" so the UI can reflect tunnel health:
+//
+//	starting → ready → (child exits) degraded → ready … | failed (restarts exhausted; the entry is forgotten)
+
+const (
+	bridgeStateEvent          = "bridge://state"
+	tunnelDefaultReadyTimeout = 5 * time.Second
+	tunnelReadyInterval       = 100 * time.Millisecond
+	tunnelPingTimeout         = time.Second
+	tunnelRestartMax          = 6
+	tunnelRestartCeiling      = 30 * time.Second
+)
+
+// tunnelRestartBaseDelay is the first restart backoff (doubled per consecutive failure, capped at the ceiling).
+// A var so the supervision test does not wait out real backoff.
+var tunnelRestartBaseDelay = time.Second
+
+// BridgeStateEvent is the bridge://state payload. Attempt counts consecutive restarts (0 for the first start);
+// Error carries the reason for degraded/failed.
+type BridgeStateEvent struct {
+	Key     string `json:"key"`
+	Kind    string `json:"kind"`
+	State   string `json:"state"`
+	Attempt int    `json:"attempt"`
+	Error   string `json:"error,omitempty"`
+}
+
+type tunnelSupervisor struct {
+	manager *bridgeManager
+	spec    bridgeSpec
+	entry   *bridgeEntry
+	stopped chan struct{}
+
+	mu      sync.Mutex
+	process *os.Process
+}
+
+// startTunnel brings the tunnel up once (a failure here is returned to the caller, as before), then hands it to
+// the supervisor goroutine. stop() ends supervision before killing the child, so a deliberate stop never
+// triggers a restart.
+func (m *bridgeManager) startTunnel(entry *bridgeEntry) error {
+	spec := entry.spec
+	supervisor := &tunnelSupervisor{manager: m, spec: spec, entry: entry, stopped: make(chan struct{})}
+	supervisor.publish("starting", 0, nil)
+	exited, err := supervisor.launch()
+	if err != nil {
+		supervisor.publish("failed", 0, entry.stderr.withTail(err))
+		return err
+	}
+	supervisor.publish("ready", 0, nil)
+	entry.tunnel = supervisor
+	var once sync.Once
+	entry.stop = func() {
+		once.Do(func() {
+			close(supervisor.stopped)
+			supervisor.kill()
+			removeLocalSocket(spec.LocalAddress)
+		})
+	}
+	go supervisor.supervise(exited)
+	return nil
+}
+
+// launch spawns the ssh child and waits until the engine answers GET /_ping through the forwarded socket (ssh
+// can bind the socket before the remote end is usable). It fails fast when the child exits first (auth/host
+// error), when ssh asks for a password or passphrase it will never get, or when the engine answers with an
+// error. Otherwise it gives up after the spec's readiness timeout, with the last probe error. The returned
+// channel closes when the child exits.
+func (t *tunnelSupervisor) launch() (<-chan struct{}, error) {
+	removeLocalSocket(t.spec.LocalAddress)
+	prompt := newPromptWatcher(t.entry.stderr)
+	cmd := exec.Command(t.spec.Launcher, t.spec.Argv...)
+	configureHiddenWindow(cmd)
+	detachFromTerminal(cmd)
+	cmd.Stderr = prompt
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+	t.mu.Lock()
+	t.process = cmd.Process
+	t.mu.Unlock()
+	timeout := tunnelDefaultReadyTimeout
+	if t.spec.ReadyTimeoutMs > 0 {
+		timeout = time.Duration(t.spec.ReadyTimeoutMs) * time.Millisecond
+	}
+	deadline := time.Now().Add(timeout)
+	lastErr := errors.New("local forward socket did not appear")
+	for {
+		err := pingTunnel(t.spec.LocalAddress)
+		if err == nil {
+			return exited, nil
+		}
+		var answered *engineAnswerError
+		if errors.As(err, &answered) {
+			_ = cmd.Process.Kill()
+			return nil, fmt.Errorf("ssh -NL tunnel: %w", err)
+		}
+		lastErr = err
+		if time.Now().After(deadline) {
+			break
+		}
+		select {
+		case <-exited:
+			return nil, errors.New("ssh -NL tunnel: ssh exited before the engine answered")
+		case <-prompt.prompted:
+			_ = cmd.Process.Kill()
+			return nil, errors.New("ssh -NL tunnel: ssh is prompting for a password or passphrase, which the app cannot answer — use key-based authentication (ssh-agent or an unencrypted identity file)")
+		case <-time.After(tunnelReadyInterval):
+		}
+	}
+	_ = cmd.Process.Kill()
+	return nil, fmt.Errorf("ssh -NL tunnel: engine not ready after %s: %w", timeout, lastErr)
+}
+
+// engineAnswerError is a non-200 /_ping: the tunnel works and the engine itself refused, so retrying is pointless.
+type engineAnswerError struct {
+	status int
+	body   string
+}
+
+func (e *engineAnswerError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("engine answered /_ping with %d", e.status)
+	}
+	return fmt.Sprintf("engine answered /_ping with %d: %s", e.status, e.body)
+}
+
+// pingTunnel dials the forwarded socket and completes one GET /_ping through it (a throwaway client, as in
+// probeEngineSocket). ssh accepting the connection proves only the local half; the reply proves the remote one.
+func pingTunnel(address string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), tunnelPingTimeout)
+	defer cancel()
+	client := &http.Client{Transport: &http.Transport{
+		DisableKeepAlives: true,
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialLocalTransport(ctx, address)
+		},
+	}}
+	response, err := probeGet(ctx, client, "/_ping")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = response.Body.Close() }()
+	if response.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+	return &engineAnswerError{status: response.StatusCode, body: strings.TrimSpace(string(body))}
+}
+
+// promptWatcher passes a tunnel child's stderr through to the bridge's stderr ring, and closes prompted when ssh
+// starts asking for a secret. Without a terminal, ssh either prints such a prompt or reports that it could not
+// run ssh-askpass; either way, the readiness wait would otherwise sit out the whole timeout.
+type promptWatcher struct {
+	stderr   *bridgeStderr
+	prompted chan struct{}
+
+	mu     sync.Mutex
+	recent []byte
+	once   sync.Once
+}
+
+var sshPromptPattern = regexp.MustCompile(`(?i)(password:|passphrase for|enter passphrase|verification code|ssh_askpass)`)
+
+func newPromptWatcher(stderr *bridgeStderr) *promptWatcher {
+	return &promptWatcher{stderr: stderr, prompted: make(chan struct{})}
+}
+
+func (w *promptWatcher) Write(p []byte) (int, error) {
+	_, _ = w.stderr.Write(p)
+	w.mu.Lock()
+	w.recent = append(w.recent, p...)
+	if len(w.recent) > 512 {
+		w.recent = w.recent[len(w.recent)-512:]
+	}
+	matched := sshPromptPattern.Match(w.recent)
+	w.mu.Unlock()
+	if matched {
+		w.once.Do(func() { close(w.prompted) })
+	}
+	return len(p), nil
+}
+
+// supervise waits for the child to exit and restarts it with backoff until it comes back ready, the bridge is
+// stopped, or tunnelRestartMax consecutive restarts fail (→ failed, and the manager forgets the entry).
+func (t *tunnelSupervisor) supervise(exited <-chan struct{}) {
+	for {
+		select {
+		case <-t.stopped:
+			return
+		case <-exited:
+		}
+		if t.isStopped() {
+			return
+		}
+		t.publish("degraded", 0, t.entry.stderr.withTail(errors.New("ssh -NL tunnel exited")))
+		var err error
+		exited, err = t.restart()
+		if err != nil {
+			if !t.isStopped() {
+				t.publish("failed", tunnelRestartMax, err)
+				t.manager.forget(t.spec.Key, t.entry)
+				removeLocalSocket(t.spec.LocalAddress)
+			}
+			return
+		}
+	}
+}
+
+func (t *tunnelSupervisor) restart() (<-chan struct{}, error) {
+	delay := tunnelRestartBaseDelay
+	var lastErr error
+	for attempt := 1; attempt <= tunnelRestartMax; attempt++ {
+		select {
+		case <-t.stopped:
+			return nil, errors.New("stopped")
+		case <-time.After(delay):
+		}
+		exited, err := t.launch()
+		if err == nil {
+			if t.isStopped() {
+				t.kill()
+				return nil, errors.New("stopped")
+			}
+			t.publish("ready", attempt, nil)
+			t.manager.persistState() // the restarted child has a new PID
+			return exited, nil
+		}
+		lastErr = t.entry.stderr.withTail(err)
+		t.publish("degraded", attempt, lastErr)
+		delay = min(delay*2, tunnelRestartCeiling)
+	}
+	return nil, fmt.Errorf("ssh -NL tunnel: gave up after %d restarts: %w", tunnelRestartMax, lastErr)
+}
+
+func (t *tunnelSupervisor) kill() {
+	t.mu.Lock()
+	process := t.process
+	t.mu.Unlock()
+	if process != nil {
+		_ = process.Kill()
+	}
+}
+
+// pid is the current child's PID (0 before the first launch).
+func (t *tunnelSupervisor) pid() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.process == nil {
+		return 0
+	}
+	return t.process.Pid
+}
+
+func (t *tunnelSupervisor) isStopped() bool {
+	select {
+	case <-t.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *tunnelSupervisor) publish(state string, attempt int, err error) {
+	event := BridgeStateEvent{Key: t.spec.Key, Kind: t.spec.Kind, State: state, Attempt: attempt}
+	if err != nil {
+		event.Error = err.Error()
+		t.entry.metrics.fail(err)
+	}
+	emitToRenderer(t.manager.emit, bridgeStateEvent, event)
+}
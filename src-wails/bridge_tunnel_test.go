@@ -0,0 +1,212 @@
+//go:build !windows
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestHelperTunnelProcess is not a real test: re-exec'd by the tunnel tests as a stand-in `ssh -NL` child. It
+// serves the engine's /_ping on the "forwarded" socket (answering CD_TUNNEL_HELPER_STATUS, default 200) until the
+// kill-file appears, then exits like a dropped tunnel.
+func TestHelperTunnelProcess(t *testing.T) {
+	socket := os.Getenv("CD_TUNNEL_HELPER_SOCKET")
+	if socket == "" {
+		t.Skip("helper process only")
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		os.Exit(2)
+	}
+	status, _ := strconv.Atoi(os.Getenv("CD_TUNNEL_HELPER_STATUS"))
+	go func() {
+		_ = http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if status != 0 && status != http.StatusOK {
+				http.Error(w, "Cannot connect to the Docker daemon", status)
+				return
+			}
+			_, _ = w.Write([]byte("OK"))
+		}))
+	}()
+	killFile := os.Getenv("CD_TUNNEL_HELPER_KILL")
+	for {
+		if _, err := os.Stat(killFile); err == nil {
+			_ = os.Remove(killFile)
+			_ = listener.Close()
+			os.Exit(0)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// A tunnel child that dies after startup is restarted: starting → ready → degraded → ready(attempt 1), and a
+// deliberate stop afterwards does not restart it again.
+func TestTunnelSupervisorRestartsDroppedTunnel(t *testing.T) {
+	previous := tunnelRestartBaseDelay
+	tunnelRestartBaseDelay = 10 * time.Millisecond
+	defer func() { tunnelRestartBaseDelay = previous }()
+
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "tunnel.sock")
+	killFile := filepath.Join(dir, "kill")
+	t.Setenv("CD_TUNNEL_HELPER_SOCKET", socket)
+	t.Setenv("CD_TUNNEL_HELPER_KILL", killFile)
+
+	var mu sync.Mutex
+	states := []BridgeStateEvent{}
+	manager := &bridgeManager{emit: func(name string, data any) {
+		if name != bridgeStateEvent {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		states = append(states, data.(BridgeStateEvent))
+	}}
+	spec := bridgeSpec{Kind: "tunnel", Key: "relay", LocalAddress: socket, Launcher: os.Args[0], Argv: []string{"-test.run=^TestHelperTunnelProcess$"}}
+	if _, err := manager.ensure(spec); err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+	if err := os.WriteFile(killFile, nil, 0o600); err != nil {
+		t.Fatalf("write kill file: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		last := BridgeStateEvent{}
+		if len(states) > 0 {
+			last = states[len(states)-1]
+		}
+		count := len(states)
+		mu.Unlock()
+		if count >= 4 && last.State == "ready" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("tunnel was not restarted: %+v", states)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	got := []string{}
+	for _, state := range states {
+		got = append(got, state.State)
+	}
+	restarted := states[len(states)-1]
+	mu.Unlock()
+	if got[0] != "starting" || got[1] != "ready" || got[2] != "degraded" || restarted.Attempt != 1 {
+		t.Fatalf("states = %v (last %+v)", got, restarted)
+	}
+
+	manager.stop("relay")
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(states) != len(got) {
+		t.Fatalf("stop triggered more transitions: %+v", states[len(got):])
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Fatalf("socket still present after stop: %v", err)
+	}
+}
+
+// Readiness is an HTTP /_ping through the tunnel: an engine error fails ensure with the engine's own text, and an
+// ssh password prompt fails it at once instead of waiting out the timeout.
+func TestTunnelReadinessProbe(t *testing.T) {
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "tunnel.sock")
+	t.Setenv("CD_TUNNEL_HELPER_SOCKET", socket)
+	t.Setenv("CD_TUNNEL_HELPER_KILL", filepath.Join(dir, "kill"))
+	t.Setenv("CD_TUNNEL_HELPER_STATUS", "500")
+	manager := &bridgeManager{emit: func(string, any) {}}
+	_, err := manager.ensure(bridgeSpec{Kind: "tunnel", Key: "engine-down", LocalAddress: socket, Launcher: os.Args[0], Argv: []string{"-test.run=^TestHelperTunnelProcess$"}})
+	if err == nil || !strings.Contains(err.Error(), "engine answered /_ping with 500: Cannot connect to the Docker daemon") {
+		t.Fatalf("ensure err = %v, want the engine's error", err)
+	}
+
+	started := time.Now()
+	_, err = manager.ensure(bridgeSpec{
+		Kind: "tunnel", Key: "password", LocalAddress: filepath.Join(dir, "prompt.sock"), ReadyTimeoutMs: 20000,
+		Launcher: "sh", Argv: []string{"-c", "printf \"me@remote's password: \" >&2; exec sleep 30"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "prompting for a password") {
+		t.Fatalf("ensure err = %v, want the password-prompt error", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("prompt detected after %s, want well before the 20s timeout", elapsed)
+	}
+}
+
+// A tunnel waiting for readiness holds no manager-wide lock: other bridges and List proceed meanwhile, and a
+// second ensure for the same key waits for the first start (one child) and shares its result.
+func TestEnsureStartsOutsideTheManagerLock(t *testing.T) {
+	dir := t.TempDir()
+	launches := filepath.Join(dir, "launches")
+	manager := &bridgeManager{emit: func(string, any) {}}
+	slow := bridgeSpec{
+		Kind: "tunnel", Key: "slow", LocalAddress: filepath.Join(dir, "slow.sock"), ReadyTimeoutMs: 1500,
+		Launcher: "sh", Argv: []string{"-c", "echo launched >> " + launches + "; exec sleep 30"},
+	}
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := manager.ensure(slow)
+			errs <- err
+		}()
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	started := time.Now()
+	manager.list()
+	if _, err := manager.ensure(bridgeSpec{Kind: "stdio", Key: "other", LocalAddress: filepath.Join(dir, "other.sock"), Launcher: "cat"}); err != nil {
+		t.Fatalf("ensure other: %v", err)
+	}
+	defer manager.stop("other")
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Errorf("other bridge operations waited %v on the starting tunnel", elapsed)
+	}
+
+	first, second := <-errs, <-errs
+	if first == nil || second == nil || first.Error() != second.Error() {
+		t.Errorf("ensure errors = %v / %v, want the same readiness failure", first, second)
+	}
+	if contents, _ := os.ReadFile(launches); strings.Count(string(contents), "launched") != 1 {
+		t.Errorf("tunnel launched %q, want once", contents)
+	}
+}
+
+// Stop on a bridge that is still starting wins: the start is torn down once it completes.
+func TestStopWhileStarting(t *testing.T) {
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "tunnel.sock")
+	t.Setenv("CD_TUNNEL_HELPER_SOCKET", socket)
+	t.Setenv("CD_TUNNEL_HELPER_KILL", filepath.Join(dir, "kill"))
+	manager := &bridgeManager{emit: func(string, any) {}}
+	done := make(chan error, 1)
+	go func() {
+		_, err := manager.ensure(bridgeSpec{
+			Kind: "tunnel", Key: "late", LocalAddress: socket,
+			Launcher: "sh", Argv: []string{"-c", `sleep 0.3; exec "$0" -test.run=^TestHelperTunnelProcess$`, os.Args[0]},
+		})
+		done <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	manager.stop("late")
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "stopped while starting") {
+		t.Fatalf("ensure err = %v, want it stopped", err)
+	}
+	if len(manager.list()) != 0 {
+		t.Errorf("bridges after stop = %+v", manager.list())
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("socket left behind: %v", err)
+	}
+}
@@ -0,0 +1,533 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+// Command policy — the renderer can ask Go to run any launcher with any args (ExecService.Execute, ProcessService
+// Spawn/Supervise, the bridge launchers, their port forwards and ssh ControlMasters, and the in-process ssh bridge
+// as the ssh command line it stands in for), and the renderer also hosts AI tooling. Every such launch is
+// authorized here first:
+//
+//   - allow: launchers by resolved absolute path (symlinks followed; filepath.Match globs allowed). Empty ⇒ any
+//     launcher that resolves.
+//   - denyArgs: regular expressions; a launch with any argument (or output file) matching one is refused. While any of them fails
+//     to compile, every launch is refused (a rule the user relies on is never silently dropped).
+//   - confirm: launcher names (sudo, pkexec, …) that need the user's consent in a native dialog — raised by Go, so
+//     the renderer cannot answer it. Also applied to the command a wrapper (env, flatpak-spawn, nohup) runs, past
+//     its options, and to every word of a shell's script (sh -c, cmd /c, powershell -Command). That is as far as it looks: an elevation tool run
+//     from a script file or an interpreter's own flag (python -c, perl -e) is for denyArgs to catch.
+//
+// The rules live in command-policy.json under userData, which only the user edits: no service writes it. Every
+// decision is appended to command-audit.log beside it, one JSON line each, with secrets redacted (redactArgs). The
+// log is kept open, and once it would pass commandAuditMaxBytes it is rotated to command-audit.log.1, the older
+// generations moving up to .2 … .commandAuditGenerations (the oldest dropped), so together they stay bounded. A
+// log that cannot be opened or written is reported in the app log, once per run of failures.
+// Like the bridge crash record, the file and the log are only used once loadCommandPolicy has run (from main), so
+// tests never touch the real userData; until then the defaults apply and no dialog can be raised, so elevated
+// launchers are refused.
+
+const (
+	commandPolicyFile = "command-policy.json"
+	commandAuditFile  = "command-audit.log"
+	// commandConfirmTimeout declines a confirmation nobody answers.
+	commandConfirmTimeout = 2 * time.Minute
+	commandAuditMaxBytes  = 4 << 20
+	// commandAuditGenerations is how many rotated logs (.1 newest) are kept beside the current one.
+	commandAuditGenerations = 5
+)
+
+// defaultConfirmLaunchers are the elevation tools that need confirmation when the file names none.
+var defaultConfirmLaunchers = []string{"sudo", "pkexec", "doas", "su", "runas", "gsudo"}
+
+// commandWrapper describes a launcher that runs the command in its arguments, for the confirm rule to look
+// through: its options that take a value (short letters, long names), and the one whose value is itself a command
+// line (env -S).
+type commandWrapper struct {
+	short   string
+	long    []string
+	command string
+}
+
+var commandWrappers = map[string]commandWrapper{
+	"env":           {short: "uCS", long: []string{"--unset", "--chdir", "--split-string"}, command: "S"},
+	"flatpak-spawn": {},
+	"nohup":         {},
+}
+
+// commandShells run the script given with -c; "o" is their one option that takes a value.
+var commandShells = map[string]bool{"sh": true, "bash": true, "dash": true, "zsh": true, "ksh": true, "ash": true}
+
+// commandWindowsShells run the rest of their command line as a script after one of these flags (any case): cmd
+// /c, /k or /r, PowerShell's -Command or -c. Their scripts are matched case-insensitively, as Windows runs them, so
+// `Start-Process -Verb RunAs` names runas.
+var commandWindowsShells = map[string][]string{
+	"cmd":        {"/c", "/k", "/r"},
+	"powershell": {"-command", "-c", "/command", "/c"},
+	"pwsh":       {"-command", "-c", "/command", "/c"},
+}
+
+// commandWrapperDepth bounds how many wrappers deep the confirm rule follows (env nohup sh -c "sudo …").
+const commandWrapperDepth = 4
+
+// commandPolicyRules is the command-policy.json format.
+type commandPolicyRules struct {
+	Allow    []string `json:"allow"`
+	DenyArgs []string `json:"denyArgs"`
+	Confirm  []string `json:"confirm"`
+}
+
+// commandRequest is one launch to authorize; Source names the caller ("execute", "spawn", "bridge", …). Output
+// is the absolute path the launch writes its stdout to (Execute's stdoutFile), when it has one.
+type commandRequest struct {
+	Source   string
+	Launcher string
+	Args     []string
+	Cwd      string
+	Output   string
+}
+
+// commandAuditEntry is one command-audit.log line. Decision is allow | deny | confirmed | declined.
+type commandAuditEntry struct {
+	Time     string   `json:"time"`
+	Source   string   `json:"source"`
+	Launcher string   `json:"launcher"`
+	Path     string   `json:"path,omitempty"`
+	Args     []string `json:"args"`
+	Cwd      string   `json:"cwd,omitempty"`
+	Output   string   `json:"output,omitempty"`
+	Decision string   `json:"decision"`
+	Reason   string   `json:"reason,omitempty"`
+}
+
+type commandPolicy struct {
+	mu       sync.Mutex
+	allow    []string
+	denyArgs []*regexp.Regexp
+	// invalid is the first denyArgs pattern that did not compile; nil ⇒ all did.
+	invalid  error
+	elevated map[string]bool
+	// auditPath is the append-only log; empty ⇒ not persisted. auditFile is it opened (on the first decision),
+	// auditSize its length, auditMax the size that rotates it. auditFailing is set while writes fail, so a
+	// failure is reported once rather than per decision.
+	auditPath    string
+	auditFile    *os.File
+	auditSize    int64
+	auditMax     int64
+	auditFailing bool
+	// confirm asks the user about an elevated launch; nil ⇒ refused.
+	confirm func(request commandRequest, path string) bool
+}
+
+// launchPolicy is shared by every service that starts a child (package-level, like bridges).
+var launchPolicy = newCommandPolicy(commandPolicyRules{})
+
+func newCommandPolicy(rules commandPolicyRules) *commandPolicy {
+	policy := &commandPolicy{elevated: map[string]bool{}, auditMax: commandAuditMaxBytes}
+	for _, entry := range rules.Allow {
+		if resolved, err := filepath.EvalSymlinks(entry); err == nil {
+			entry = resolved
+		}
+		policy.allow = append(policy.allow, filepath.Clean(entry))
+	}
+	for _, pattern := range rules.DenyArgs {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			if policy.invalid == nil {
+				policy.invalid = fmt.Errorf("invalid denyArgs pattern %q: %w", pattern, err)
+			}
+			continue
+		}
+		policy.denyArgs = append(policy.denyArgs, compiled)
+	}
+	confirm := rules.Confirm
+	if confirm == nil {
+		confirm = defaultConfirmLaunchers
+	}
+	for _, name := range confirm {
+		policy.elevated[launcherName(name)] = true
+	}
+	return policy
+}
+
+// loadCommandPolicy reads the user's rules and turns on the audit log and the confirmation dialog. Called from
+// main before the app runs. A missing file keeps the defaults; an unreadable one is reported and ignored.
+func loadCommandPolicy() {
+	base, err := userDataPath()
+	if err != nil {
+		return
+	}
+	var rules commandPolicyRules
+	if contents, err := os.ReadFile(filepath.Join(base, commandPolicyFile)); err == nil {
+		if err := json.Unmarshal(contents, &rules); err != nil {
+			fmt.Fprintf(os.Stderr, "container-desktop: ignoring %s: %v\n", commandPolicyFile, err)
+			rules = commandPolicyRules{}
+		}
+	}
+	policy := newCommandPolicy(rules)
+	if policy.invalid != nil {
+		fmt.Fprintf(os.Stderr, "container-desktop: %s: %v; refusing every launch until it is fixed\n", commandPolicyFile, policy.invalid)
+	}
+	policy.auditPath = filepath.Join(base, commandAuditFile)
+	policy.confirm = confirmWithDialog
+	launchPolicy = policy
+}
+
+// authorize decides a launch and records the decision. The error explains a refusal.
+func (p *commandPolicy) authorize(request commandRequest) error {
+	path, decision, reason := p.decide(request)
+	if decision == "confirm" {
+		switch {
+		case p.confirm == nil:
+			decision, reason = "declined", reason+": no confirmation available"
+		case p.confirm(request, path):
+			decision = "confirmed"
+		default:
+			decision, reason = "declined", reason+": declined by the user"
+		}
+	}
+	p.audit(commandAuditEntry{
+		Time:     time.Now().UTC().Format(time.RFC3339Nano),
+		Source:   request.Source,
+		Launcher: request.Launcher,
+		Path:     path,
+		Args:     redactArgs(request.Args),
+		Cwd:      request.Cwd,
+		Output:   request.Output,
+		Decision: decision,
+		Reason:   reason,
+	})
+	if decision == "deny" || decision == "declined" {
+		return fmt.Errorf("command policy: %s", reason)
+	}
+	return nil
+}
+
+// decide resolves the launcher and applies the rules: deny, confirm or allow (with the rule that decided). A
+// launcher that does not resolve is left for exec to report, unless an allow-list is in force.
+func (p *commandPolicy) decide(request commandRequest) (path, decision, reason string) {
+	path, err := resolveLauncher(request.Launcher, request.Cwd)
+	if p.invalid != nil {
+		return path, "deny", p.invalid.Error()
+	}
+	if len(p.allow) > 0 {
+		if err != nil {
+			return "", "deny", err.Error()
+		}
+		if !p.allowed(path) {
+			return path, "deny", path + " is not on the allow-list"
+		}
+	}
+	for _, arg := range request.Args {
+		for _, pattern := range p.denyArgs {
+			if pattern.MatchString(arg) {
+				return path, "deny", fmt.Sprintf("argument %q matches denied pattern %q", redactArg(arg), pattern)
+			}
+		}
+	}
+	for _, pattern := range p.denyArgs {
+		if request.Output != "" && pattern.MatchString(request.Output) {
+			return path, "deny", fmt.Sprintf("output file %q matches denied pattern %q", request.Output, pattern)
+		}
+	}
+	if name := p.elevatedLauncher(request.Launcher, path, request.Args); name != "" {
+		return path, "confirm", "elevated launcher " + name
+	}
+	return path, "allow", ""
+}
+
+func (p *commandPolicy) allowed(path string) bool {
+	for _, entry := range p.allow {
+		if runtime.GOOS == "windows" {
+			entry, path = strings.ToLower(entry), strings.ToLower(path)
+		}
+		if entry == path {
+			return true
+		}
+		if matched, _ := filepath.Match(entry, path); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// elevatedLauncher names the elevation tool a launch runs, directly or through a wrapper or shell ("" when none).
+// Both the requested name and the resolved one count, so neither a symlink nor a renamed copy hides it.
+func (p *commandPolicy) elevatedLauncher(launcher, path string, args []string) string {
+	return p.elevatedCommand(launcher, path, args, 0)
+}
+
+func (p *commandPolicy) elevatedCommand(launcher, path string, args []string, depth int) string {
+	if path == "" {
+		path, _ = resolveLauncher(launcher, "")
+	}
+	names := []string{launcherName(launcher)}
+	if path != "" {
+		names = append(names, launcherName(path))
+	}
+	for _, name := range names {
+		if p.elevated[name] {
+			return name
+		}
+	}
+	if depth >= commandWrapperDepth {
+		return ""
+	}
+	for _, name := range names {
+		if wrapper, ok := commandWrappers[name]; ok {
+			return p.elevatedWrapped(wrapper, args, depth)
+		}
+		if commandShells[name] {
+			return p.elevatedShell(args)
+		}
+		if flags, ok := commandWindowsShells[name]; ok {
+			return p.elevatedWindowsShell(flags, args)
+		}
+	}
+	return ""
+}
+
+// elevatedWrapped skips a wrapper's options (and their values) and NAME=value assignments, then checks the
+// command that follows.
+func (p *commandPolicy) elevatedWrapped(wrapper commandWrapper, args []string, depth int) string {
+	for index := 0; index < len(args); index++ {
+		arg := args[index]
+		switch {
+		case arg == "--":
+			if index+1 < len(args) {
+				return p.elevatedCommand(args[index+1], "", args[index+2:], depth+1)
+			}
+			return ""
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg, "=")
+			if !slices.Contains(wrapper.long, name) {
+				continue
+			}
+			if !hasValue && index+1 < len(args) {
+				index++
+				value = args[index]
+			}
+			if name == "--split-string" {
+				return p.elevatedScript(value)
+			}
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			for position := 1; position < len(arg); position++ {
+				letter := arg[position]
+				if strings.IndexByte(wrapper.short, letter) < 0 {
+					continue
+				}
+				value := arg[position+1:]
+				if value == "" && index+1 < len(args) {
+					index++
+					value = args[index]
+				}
+				if strings.IndexByte(wrapper.command, letter) >= 0 {
+					return p.elevatedScript(value)
+				}
+				break
+			}
+		case strings.Contains(arg, "="):
+		default:
+			return p.elevatedCommand(arg, "", args[index+1:], depth+1)
+		}
+	}
+	return ""
+}
+
+// elevatedShell checks the script of `sh -c script …` (-c may share a cluster: -ec, -lc). A shell running a
+// script file is not looked into.
+func (p *commandPolicy) elevatedShell(args []string) string {
+	command := false
+	for index := 0; index < len(args); index++ {
+		arg := args[index]
+		if arg == "--" {
+			index++
+		} else if (strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "+")) && len(arg) > 1 {
+			command = command || (arg[0] == '-' && strings.Contains(arg[1:], "c"))
+			if strings.HasSuffix(arg, "o") {
+				index++ // -o option / +o option
+			}
+			continue
+		}
+		if command && index < len(args) {
+			return p.elevatedScript(args[index])
+		}
+		return ""
+	}
+	return ""
+}
+
+// elevatedWindowsShell checks the script that follows cmd's /c or PowerShell's -Command: the rest of the
+// arguments, joined as the shell joins them.
+func (p *commandPolicy) elevatedWindowsShell(flags, args []string) string {
+	for index, arg := range args {
+		if !slices.Contains(flags, strings.ToLower(arg)) {
+			continue
+		}
+		for _, word := range scriptWords(strings.Join(args[index+1:], " ")) {
+			name := strings.ToLower(word[strings.LastIndexAny(word, `/\`)+1:])
+			if name = strings.TrimSuffix(name, ".exe"); p.elevated[name] {
+				return name
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+// elevatedScript looks for an elevation tool among every word of a command line. Without parsing the shell it
+// cannot tell a command from an argument, so any mention needs confirmation — the safe side to err on.
+func (p *commandPolicy) elevatedScript(script string) string {
+	for _, word := range scriptWords(script) {
+		if name := launcherName(word); p.elevated[name] {
+			return name
+		}
+	}
+	return ""
+}
+
+func scriptWords(script string) []string {
+	return strings.FieldsFunc(script, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(";&|()<>`'\"$={}!", r)
+	})
+}
+
+// audit appends one line to the log, rotating it first when the line would take it past auditMax. The file is
+// only ever opened for appending.
+func (p *commandPolicy) audit(entry commandAuditEntry) {
+	if p.auditPath == "" {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.auditFile != nil && p.auditSize > 0 && p.auditSize+int64(len(line)) > p.auditMax {
+		_ = p.auditFile.Close()
+		p.auditFile = nil
+		p.rotateAuditLocked()
+	}
+	if p.auditFile == nil {
+		file, err := os.OpenFile(p.auditPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			p.auditFailedLocked(err)
+			return
+		}
+		p.auditFile, p.auditSize = file, 0
+		if info, err := file.Stat(); err == nil {
+			p.auditSize = info.Size()
+		}
+	}
+	written, err := p.auditFile.Write(line)
+	p.auditSize += int64(written)
+	if err != nil {
+		// Reopen on the next decision, in case the file was the problem (removed, or its disk remounted).
+		_ = p.auditFile.Close()
+		p.auditFile = nil
+		p.auditFailedLocked(err)
+		return
+	}
+	p.auditFailing = false
+}
+
+// rotateAuditLocked shifts the rotated logs up one generation (.1 → .2 …, dropping the oldest) and moves the
+// current log to .1.
+func (p *commandPolicy) rotateAuditLocked() {
+	_ = os.Remove(fmt.Sprintf("%s.%d", p.auditPath, commandAuditGenerations))
+	for generation := commandAuditGenerations - 1; generation >= 1; generation-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", p.auditPath, generation), fmt.Sprintf("%s.%d", p.auditPath, generation+1))
+	}
+	_ = os.Rename(p.auditPath, p.auditPath+".1")
+}
+
+// auditFailedLocked reports a decision that could not be recorded, once until a write succeeds again.
+func (p *commandPolicy) auditFailedLocked(err error) {
+	if p.auditFailing {
+		return
+	}
+	p.auditFailing = true
+	appendLogLine("WARN", "[policy] cannot write "+commandAuditFile+": "+err.Error())
+}
+
+// resolveLauncher is the absolute path a launcher runs from, as exec.Command would find it (PATH, or relative to
+// cwd), with symlinks followed.
+func resolveLauncher(launcher, cwd string) (string, error) {
+	if launcher == "" {
+		return "", errors.New("launcher is empty")
+	}
+	path := launcher
+	if !filepath.IsAbs(path) && strings.ContainsAny(path, `/\`) && cwd != "" {
+		path = filepath.Join(cwd, path)
+	}
+	path, err := exec.LookPath(path)
+	if err != nil {
+		return "", err
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return path, nil
+}
+
+// launcherName is the comparable name of a launcher: its base name, without .exe and case-folded on Windows.
+func launcherName(launcher string) string {
+	name := filepath.Base(launcher)
+	if runtime.GOOS == "windows" {
+		name = strings.TrimSuffix(strings.ToLower(name), ".exe")
+	}
+	return name
+}
+
+// confirmWithDialog asks in a native dialog over the app window; no answer within commandConfirmTimeout declines.
+func confirmWithDialog(request commandRequest, path string) bool {
+	app := application.Get()
+	if app == nil {
+		return false
+	}
+	answer := make(chan bool, 1)
+	reply := func(confirmed bool) func() {
+		return func() {
+			select {
+			case answer <- confirmed:
+			default:
+			}
+		}
+	}
+	dialog := app.Dialog.Question().
+		SetTitle("Run a command with elevated privileges?").
+		SetMessage(fmt.Sprintf("Container Desktop wants to run:\n\n%s %s\n\n(%s)", path,
+			strings.Join(redactArgs(request.Args), " "), request.Source))
+	dialog.AddButton("Run").OnClick(reply(true))
+	cancel := dialog.AddButton("Cancel").OnClick(reply(false))
+	dialog.SetDefaultButton(cancel).SetCancelButton(cancel)
+	if mainWindow != nil {
+		dialog.AttachToWindow(mainWindow)
+	}
+	dialog.Show()
+	select {
+	case confirmed := <-answer:
+		return confirmed
+	case <-time.After(commandConfirmTimeout):
+		return false
+	}
+}
@@ -0,0 +1,361 @@
+//go:build !windows
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// withLaunchPolicy installs policy as the shared one for the test, auditing to a temp file it returns.
+func withLaunchPolicy(t *testing.T, policy *commandPolicy) string {
+	t.Helper()
+	policy.auditPath = filepath.Join(t.TempDir(), commandAuditFile)
+	previous := launchPolicy
+	launchPolicy = policy
+	t.Cleanup(func() { launchPolicy = previous })
+	return policy.auditPath
+}
+
+func readAudit(t *testing.T, path string) []commandAuditEntry {
+	t.Helper()
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("audit log: %v", err)
+	}
+	var entries []commandAuditEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(contents)), "\n") {
+		var entry commandAuditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("audit line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func mustResolve(t *testing.T, launcher string) string {
+	t.Helper()
+	path, err := resolveLauncher(launcher, "")
+	if err != nil {
+		t.Fatalf("resolve %s: %v", launcher, err)
+	}
+	return path
+}
+
+// An allow-list admits launchers by their resolved path — through a symlink on either side — and nothing else.
+func TestCommandPolicyAllowList(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(dir, "true-link")
+	if err := os.Symlink(mustResolve(t, "true"), link); err != nil {
+		t.Fatal(err)
+	}
+	policy := newCommandPolicy(commandPolicyRules{Allow: []string{link, filepath.Dir(mustResolve(t, "cat")) + "/ca?"}})
+	for launcher, allowed := range map[string]bool{"true": true, link: true, "cat": true, "echo": false, "no-such-launcher": false} {
+		err := policy.authorize(commandRequest{Source: "test", Launcher: launcher})
+		if (err == nil) != allowed {
+			t.Errorf("%s: err = %v, want allowed %v", launcher, err, allowed)
+		}
+	}
+	// Without an allow-list any launcher passes; one that does not resolve is left for exec to report.
+	if err := newCommandPolicy(commandPolicyRules{}).authorize(commandRequest{Launcher: "no-such-launcher"}); err != nil {
+		t.Errorf("default policy refused an unknown launcher: %v", err)
+	}
+}
+
+// Denied argument patterns refuse the launch, and the audit line and the error keep secrets redacted.
+func TestCommandPolicyDenyArgsAndAudit(t *testing.T) {
+	audit := withLaunchPolicy(t, newCommandPolicy(commandPolicyRules{DenyArgs: []string{`^--privileged`, `^--password=`}}))
+	result := (&ExecService{}).Execute(CommandExecuteRequest{Launcher: "echo", Args: []string{"run", "--privileged", "alpine"}})
+	if result.Success || !strings.Contains(result.Stderr, `command policy: argument "--privileged"`) {
+		t.Errorf("result = %+v, want a policy refusal", result)
+	}
+	err := launchPolicy.authorize(commandRequest{Source: "spawn", Launcher: "echo", Args: []string{"login", "--password=hunter2"}})
+	if err == nil || strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("err = %v, want a refusal without the secret", err)
+	}
+	if result := (&ExecService{}).Execute(CommandExecuteRequest{Launcher: "echo", Args: []string{"ok"}}); result.Stdout != "ok\n" {
+		t.Errorf("allowed run = %+v", result)
+	}
+
+	entries := readAudit(t, audit)
+	if len(entries) != 3 {
+		t.Fatalf("audit = %+v, want three decisions", entries)
+	}
+	decisions := []string{entries[0].Decision, entries[1].Decision, entries[2].Decision}
+	if strings.Join(decisions, ",") != "deny,deny,allow" || entries[0].Source != "execute" || entries[2].Path != mustResolve(t, "echo") {
+		t.Errorf("audit = %+v", entries)
+	}
+	contents, _ := os.ReadFile(audit)
+	if strings.Contains(string(contents), "hunter2") {
+		t.Errorf("audit log leaks a secret: %s", contents)
+	}
+}
+
+// A denyArgs pattern that does not compile refuses every launch, with or without arguments, and says why.
+func TestCommandPolicyInvalidDenyPattern(t *testing.T) {
+	policy := newCommandPolicy(commandPolicyRules{DenyArgs: []string{`^--ok$`, `(unclosed`}})
+	for _, args := range [][]string{nil, {"ps"}} {
+		err := policy.authorize(commandRequest{Launcher: "echo", Args: args})
+		if err == nil || !strings.Contains(err.Error(), `invalid denyArgs pattern "(unclosed"`) {
+			t.Errorf("args %q: err = %v, want a refusal naming the broken pattern", args, err)
+		}
+	}
+}
+
+// Elevated launchers need the confirmation hook's consent — also behind a wrapper or a renamed symlink — and are
+// refused when there is no hook.
+func TestCommandPolicyConfirmation(t *testing.T) {
+	dir := t.TempDir()
+	sudo := filepath.Join(dir, "sudo")
+	if err := os.Symlink(mustResolve(t, "true"), sudo); err != nil {
+		t.Fatal(err)
+	}
+	policy := newCommandPolicy(commandPolicyRules{})
+	audit := withLaunchPolicy(t, policy)
+	if err := policy.authorize(commandRequest{Launcher: sudo}); err == nil || !strings.Contains(err.Error(), "no confirmation available") {
+		t.Errorf("err = %v, want a refusal without a confirmation hook", err)
+	}
+
+	var asked []string
+	answer := false
+	policy.confirm = func(request commandRequest, _ string) bool {
+		asked = append(asked, request.Launcher+" "+strings.Join(request.Args, " "))
+		return answer
+	}
+	if err := policy.authorize(commandRequest{Launcher: "env", Args: []string{"-i", "A=1", sudo, "ls"}}); err == nil {
+		t.Error("a declined sudo behind env ran")
+	}
+	answer = true
+	if _, err := (&ProcessService{emit: func(string, any) {}}).Spawn(processSpawnArgs{Payload: SpawnPayload{Launcher: sudo}, Channel: 91}); err != nil {
+		t.Errorf("confirmed spawn: %v", err)
+	}
+	if err := policy.authorize(commandRequest{Launcher: "echo", Args: []string{"sudo"}}); err != nil || len(asked) != 2 {
+		t.Errorf("plain echo: err %v, asked %v", err, asked)
+	}
+
+	var decisions []string
+	for _, entry := range readAudit(t, audit) {
+		decisions = append(decisions, entry.Decision)
+	}
+	if strings.Join(decisions, ",") != "declined,declined,confirmed,allow" {
+		t.Errorf("decisions = %v", decisions)
+	}
+}
+
+// The confirm rule looks past a wrapper's options and their values, and into shell -c scripts.
+func TestCommandPolicyElevatedThroughWrappers(t *testing.T) {
+	policy := newCommandPolicy(commandPolicyRules{})
+	for _, test := range []struct {
+		launcher string
+		args     []string
+		want     string
+	}{
+		{"env", []string{"-u", "HOME", "sudo", "ls"}, "sudo"},
+		{"env", []string{"--unset", "HOME", "-C", "/tmp", "A=1", "doas", "ls"}, "doas"},
+		{"env", []string{"-uHOME", "--chdir=/tmp", "--", "pkexec", "ls"}, "pkexec"},
+		{"env", []string{"-S", "sudo ls"}, "sudo"},
+		{"nohup", []string{"env", "-i", "/usr/bin/sudo", "ls"}, "sudo"},
+		{"sh", []string{"-c", "cd /tmp && sudo ls"}, "sudo"},
+		{"bash", []string{"-lc", "exec su -"}, "su"},
+		{"bash", []string{"-o", "pipefail", "-c", "true | pkexec tee /etc/x"}, "pkexec"},
+		{"flatpak-spawn", []string{"--host", "--env=A=1", "sh", "-c", "sudo ls"}, "sudo"},
+		{"cmd", []string{"/C", "runas", "/user:Administrator", "regedit"}, "runas"},
+		{"cmd", []string{"/d", "/c", `C:\Tools\gsudo.exe whoami`}, "gsudo"},
+		{"powershell", []string{"-NoProfile", "-Command", "Start-Process cmd -Verb RunAs"}, "runas"},
+		{"pwsh", []string{"-c", "Start-Process", "-Verb", "RunAs", "-FilePath", "notepad"}, "runas"},
+		{"cmd", []string{"/c", "echo runas-free"}, ""},
+		{"powershell", []string{"-File", "runas.ps1"}, ""},
+		{"env", []string{"-u", "sudo", "ls"}, ""},
+		{"sh", []string{"script.sh", "sudo"}, ""},
+		{"sh", []string{"-c", "echo hello"}, ""},
+	} {
+		if got := policy.elevatedLauncher(test.launcher, "", test.args); got != test.want {
+			t.Errorf("%s %q: elevated %q, want %q", test.launcher, test.args, got, test.want)
+		}
+	}
+}
+
+// The audit log is only appended to: existing lines survive, and a policy loaded from userData writes beside it.
+func TestLoadCommandPolicyAppendsAudit(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CONTAINER_DESKTOP_USER_DATA_DIR", dir)
+	rules := `{"allow": [], "denyArgs": ["^rm$"], "confirm": ["doas"]}`
+	if err := os.WriteFile(filepath.Join(dir, commandPolicyFile), []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	audit := filepath.Join(dir, commandAuditFile)
+	if err := os.WriteFile(audit, []byte(`{"decision":"allow","source":"earlier"}`+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	previous := launchPolicy
+	t.Cleanup(func() { launchPolicy = previous })
+	loadCommandPolicy()
+	launchPolicy.confirm = nil
+
+	if err := launchPolicy.authorize(commandRequest{Source: "test", Launcher: "echo", Args: []string{"rm"}}); err == nil {
+		t.Error("denyArgs from the file not applied")
+	}
+	if _, _, reason := launchPolicy.decide(commandRequest{Launcher: "sudo"}); reason != "" {
+		t.Errorf("confirm list from the file not applied: sudo needs %q", reason)
+	}
+	entries := readAudit(t, audit)
+	if len(entries) != 2 || entries[0].Source != "earlier" || entries[1].Decision != "deny" {
+		t.Errorf("audit = %+v", entries)
+	}
+	if info, err := os.Stat(audit); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("audit log mode = %v (%v)", info.Mode(), err)
+	}
+}
+
+// Past its size cap the audit log rotates through numbered generations (.1 newest), dropping the oldest past
+// commandAuditGenerations, never splitting a line; no file holds a secret (login -p, a password nested in a flag
+// value).
+func TestCommandAuditRotates(t *testing.T) {
+	policy := newCommandPolicy(commandPolicyRules{})
+	audit := withLaunchPolicy(t, policy)
+	policy.auditMax = 1024
+	for range 120 {
+		if err := policy.authorize(commandRequest{Source: "test", Launcher: "echo", Args: []string{"login", "-p", "hunter2", "--env=DB_PASSWORD=hunter2"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paths := []string{audit}
+	for generation := 1; generation <= commandAuditGenerations; generation++ {
+		paths = append(paths, fmt.Sprintf("%s.%d", audit, generation))
+	}
+	var newer time.Time
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if info.Size() > policy.auditMax || info.Mode().Perm() != 0o600 {
+			t.Errorf("%s: %d bytes, mode %v; want at most %d, 0600", path, info.Size(), info.Mode(), policy.auditMax)
+		}
+		entries := readAudit(t, path)
+		for _, entry := range entries {
+			if entry.Decision != "allow" || strings.Contains(strings.Join(entry.Args, " "), "hunter2") {
+				t.Errorf("%s: entry %+v", path, entry)
+			}
+		}
+		// Each generation ends no later than the next newer one begins.
+		first, _ := time.Parse(time.RFC3339Nano, entries[0].Time)
+		last, _ := time.Parse(time.RFC3339Nano, entries[len(entries)-1].Time)
+		if !newer.IsZero() && last.After(newer) {
+			t.Errorf("%s ends at %v, after the newer generation starts at %v", path, last, newer)
+		}
+		newer = first
+	}
+	if matches, _ := filepath.Glob(audit + ".*"); len(matches) != commandAuditGenerations {
+		t.Errorf("rotated logs = %v, want %d generations", matches, commandAuditGenerations)
+	}
+}
+
+// An audit log that cannot be written is reported in the app log, once for a run of failures.
+func TestCommandAuditReportsFailures(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CONTAINER_DESKTOP_USER_DATA_DIR", dir)
+	policy := newCommandPolicy(commandPolicyRules{})
+	withLaunchPolicy(t, policy)
+	policy.auditPath = filepath.Join(dir, "missing", commandAuditFile)
+	for range 3 {
+		if err := policy.authorize(commandRequest{Source: "test", Launcher: "echo"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logPath, _ := logFilePath()
+	contents, _ := os.ReadFile(logPath)
+	if got := strings.Count(string(contents), "[policy] cannot write "+commandAuditFile); got != 1 {
+		t.Errorf("app log reported the audit failure %d times, want once:\n%s", got, contents)
+	}
+}
+
+// Concurrent ensures of one bridge share a single authorization: one confirmation, one audit line.
+func TestBridgeEnsureSharesAuthorization(t *testing.T) {
+	dir := t.TempDir()
+	sudo := filepath.Join(dir, "sudo")
+	if err := os.Symlink(mustResolve(t, "cat"), sudo); err != nil {
+		t.Fatal(err)
+	}
+	policy := newCommandPolicy(commandPolicyRules{})
+	audit := withLaunchPolicy(t, policy)
+	var asked atomic.Int32
+	policy.confirm = func(commandRequest, string) bool {
+		asked.Add(1)
+		time.Sleep(200 * time.Millisecond)
+		return true
+	}
+	manager := &bridgeManager{}
+	spec := bridgeSpec{Kind: "stdio", Key: "elevated", LocalAddress: filepath.Join(dir, "bridge.sock"), Launcher: sudo}
+	errs := make(chan error, 3)
+	for range 3 {
+		go func() {
+			_, err := manager.ensure(spec)
+			errs <- err
+		}()
+	}
+	for range 3 {
+		if err := <-errs; err != nil {
+			t.Fatalf("ensure: %v", err)
+		}
+	}
+	defer manager.stop("elevated")
+	if asked.Load() != 1 {
+		t.Errorf("confirmation raised %d times, want once", asked.Load())
+	}
+	if entries := readAudit(t, audit); len(entries) != 1 || entries[0].Decision != "confirmed" {
+		t.Errorf("audit = %+v, want one confirmed decision", entries)
+	}
+}
+
+// The in-process ssh bridge, a port forward's `ssh -W` child and the ControlMaster are each authorized as launches
+// of their own: a denyArgs pattern refuses every one, and every refusal is audited under its source.
+func TestBridgeSSHLaunchesAreAuthorized(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("FAKE_SSH_LOG", filepath.Join(dir, "ssh.log"))
+	launcher := filepath.Join(dir, "ssh")
+	if err := os.WriteFile(launcher, []byte(fakeSSHScript), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	audit := withLaunchPolicy(t, newCommandPolicy(commandPolicyRules{DenyArgs: []string{`^denied-user$`, `^localhost:8080$`, `ControlMaster=yes`}}))
+	manager := &bridgeManager{}
+
+	sshSpec := sshBridgeSpec{Host: "127.0.0.1", Port: 1, User: "denied-user", Command: "docker system dial-stdio"}
+	if _, err := manager.ensure(bridgeSpec{Kind: "ssh", Key: "in-process", LocalAddress: filepath.Join(dir, "ssh.sock"), SSH: &sshSpec}); err == nil || !strings.Contains(err.Error(), "command policy") {
+		t.Errorf("ssh bridge ensure error = %v, want a policy refusal", err)
+	}
+
+	manager.entries = map[string]*bridgeEntry{"tunnel": {spec: bridgeSpec{
+		Kind: "tunnel", Key: "tunnel", Launcher: launcher, Argv: []string{"-NL", "/local.sock:/remote.sock", "me@remote"},
+	}, stderr: newBridgeStderr("tunnel")}}
+	if _, err := manager.createForward(PortForwardRequest{Key: "tunnel", RemotePort: 8080}); err == nil || !strings.Contains(err.Error(), "command policy") {
+		t.Errorf("forward error = %v, want a policy refusal", err)
+	}
+
+	master := &sshMaster{controlPath: filepath.Join(dir, "cm"), launcher: launcher, target: "me@remote", stderr: newBridgeStderr("master")}
+	if err := master.launch(); err == nil || !strings.Contains(err.Error(), "command policy") {
+		t.Errorf("master launch error = %v, want a policy refusal", err)
+	}
+	if fileExists(filepath.Join(dir, "ssh.log")) {
+		t.Error("a refused launch ran ssh")
+	}
+
+	entries := readAudit(t, audit)
+	sources := []string{}
+	for _, entry := range entries {
+		if entry.Decision != "deny" {
+			t.Errorf("audit entry %+v, want deny", entry)
+		}
+		sources = append(sources, entry.Source)
+	}
+	if strings.Join(sources, ",") != "bridge-ssh,forward,ssh-master" {
+		t.Errorf("audited sources = %v", sources)
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EngineConnection is a remote/local engine endpoint the user already defined with the engine CLIs — a
+// `docker context create` entry or a `podman system connection add` entry — normalized so the connection picker
+// can offer it the way GetSSHConfig offers SSH hosts. Scheme is the URI scheme ("unix" | "npipe" | "ssh" | "tcp");
+// Host/Port/User/Path are split out of it (Path is the remote socket for ssh://, the local socket for unix://).
+type EngineConnection struct {
+	Name         string               `json:"name"`
+	Engine       string               `json:"engine"`
+	Source       string               `json:"source"`
+	URI          string               `json:"uri"`
+	Scheme       string               `json:"scheme"`
+	Host         string               `json:"host,omitempty"`
+	Port         uint32               `json:"port,omitempty"`
+	User         string               `json:"user,omitempty"`
+	Path         string               `json:"path,omitempty"`
+	IdentityFile string               `json:"identityFile,omitempty"`
+	TLS          *EngineConnectionTLS `json:"tls,omitempty"`
+	Description  string               `json:"description,omitempty"`
+	IsDefault    bool                 `json:"isDefault"`
+	IsMachine    bool                 `json:"isMachine"`
+}
+
+// EngineConnectionTLS points at a docker context's TLS material (paths only — the files are never read here).
+type EngineConnectionTLS struct {
+	CAFile        string `json:"caFile,omitempty"`
+	CertFile      string `json:"certFile,omitempty"`
+	KeyFile       string `json:"keyFile,omitempty"`
+	SkipTLSVerify bool   `json:"skipTLSVerify"`
+}
+
+// GetEngineConnections reads the Docker contexts (~/.docker/contexts, honoring DOCKER_CONFIG) and the Podman
+// system connections (podman-connections.json, then the containers.conf service_destinations tables). Infallible
+// like GetSSHConfig: a missing/corrupt store contributes nothing. A name defined in both Podman stores is listed
+// once — podman-connections.json wins, as in Podman 5.
+func (s *PlatformService) GetEngineConnections() []EngineConnection {
+	home, _ := os.UserHomeDir()
+	connections := readDockerContexts(dockerConfigDir(home))
+	return append(connections, readPodmanConnections(home)...)
+}
+
+func dockerConfigDir(home string) string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+	return filepath.Join(home, ".docker")
+}
+
+// readDockerContexts walks contexts/meta/<digest>/meta.json; TLS material for a context lives beside it under
+// contexts/tls/<digest>/docker/{ca,cert,key}.pem. config.json's currentContext marks the default.
+func readDockerContexts(configDir string) []EngineConnection {
+	out := []EngineConnection{}
+	if configDir == "" {
+		return out
+	}
+	current := ""
+	if contents, err := os.ReadFile(filepath.Join(configDir, "config.json")); err == nil {
+		var config struct {
+			CurrentContext string `json:"currentContext"`
+		}
+		if json.Unmarshal(contents, &config) == nil {
+			current = config.CurrentContext
+		}
+	}
+	metas, _ := filepath.Glob(filepath.Join(configDir, "contexts", "meta", "*", "meta.json"))
+	for _, metaPath := range metas {
+		contents, err := os.ReadFile(metaPath)
+		if err != nil {
+			continue
+		}
+		digest := filepath.Base(filepath.Dir(metaPath))
+		connection, ok := parseDockerContextMeta(contents, filepath.Join(configDir, "contexts", "tls", digest, "docker"))
+		if !ok {
+			continue
+		}
+		connection.IsDefault = connection.Name == current
+		out = append(out, connection)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// parseDockerContextMeta parses one meta.json. Only the "docker" endpoint is an engine endpoint (a context may
+// also carry a kubernetes one). tlsDir is checked for the ca/cert/key the CLI stores when created with --docker tls*.
+func parseDockerContextMeta(contents []byte, tlsDir string) (EngineConnection, bool) {
+	var meta struct {
+		Name     string `json:"Name"`
+		Metadata struct {
+			Description string `json:"Description"`
+		} `json:"Metadata"`
+		Endpoints map[string]struct {
+			Host          string `json:"Host"`
+			SkipTLSVerify bool   `json:"SkipTLSVerify"`
+		} `json:"Endpoints"`
+	}
+	if json.Unmarshal(contents, &meta) != nil || meta.Name == "" {
+		return EngineConnection{}, false
+	}
+	endpoint, ok := meta.Endpoints["docker"]
+	if !ok || endpoint.Host == "" {
+		return EngineConnection{}, false
+	}
+	connection := newEngineConnection(meta.Name, "docker", "docker-context", endpoint.Host)
+	connection.Description = meta.Metadata.Description
+	tls := &EngineConnectionTLS{SkipTLSVerify: endpoint.SkipTLSVerify}
+	if file := filepath.Join(tlsDir, "ca.pem"); fileExists(file) {
+		tls.CAFile = file
+	}
+	if file := filepath.Join(tlsDir, "cert.pem"); fileExists(file) {
+		tls.CertFile = file
+	}
+	if file := filepath.Join(tlsDir, "key.pem"); fileExists(file) {
+		tls.KeyFile = file
+	}
+	if tls.CAFile != "" || tls.CertFile != "" || tls.KeyFile != "" || tls.SkipTLSVerify {
+		connection.TLS = tls
+	}
+	return connection, true
+}
+
+// podmanConfigDirs are the containers config roots, user first: $XDG_CONFIG_HOME (or the per-OS config dir) then
+// the system-wide /etc/containers + /usr/share/containers (Linux only).
+func podmanConfigDirs(home string) []string {
+	dirs := []string{}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		dirs = append(dirs, filepath.Join(xdg, "containers"))
+	} else if home != "" {
+		dirs = append(dirs, filepath.Join(home, ".config", "containers"))
+	}
+	if runtime.GOOS == "linux" {
+		dirs = append(dirs, "/etc/containers", "/usr/share/containers")
+	}
+	return dirs
+}
+
+// readPodmanConnections merges podman-connections.json (Podman 5, user-only) with the containers.conf tables
+// (user, then system). The first definition of a name wins.
+func readPodmanConnections(home string) []EngineConnection {
+	out := []EngineConnection{}
+	seen := map[string]bool{}
+	merge := func(connections []EngineConnection) {
+		for _, connection := range connections {
+			if !seen[connection.Name] {
+				seen[connection.Name] = true
+				out = append(out, connection)
+			}
+		}
+	}
+	dirs := podmanConfigDirs(home)
+	if len(dirs) > 0 {
+		if contents, err := os.ReadFile(filepath.Join(dirs[0], "podman-connections.json")); err == nil {
+			merge(parsePodmanConnectionsJSON(contents))
+		}
+	}
+	for _, dir := range dirs {
+		if contents, err := os.ReadFile(filepath.Join(dir, "containers.conf")); err == nil {
+			merge(parseContainersConfConnections(string(contents)))
+		}
+	}
+	return out
+}
+
+// parsePodmanConnectionsJSON reads Podman 5's connection table:
+// {"Connection":{"Default":"x","Connections":{"x":{"URI":"ssh://…","Identity":"…","IsMachine":true}}}}.
+func parsePodmanConnectionsJSON(contents []byte) []EngineConnection {
+	var file struct {
+		Connection struct {
+			Default     string `json:"Default"`
+			Connections map[string]struct {
+				URI       string `json:"URI"`
+				Identity  string `json:"Identity"`
+				IsMachine bool   `json:"IsMachine"`
+			} `json:"Connections"`
+		} `json:"Connection"`
+	}
+	out := []EngineConnection{}
+	if json.Unmarshal(contents, &file) != nil {
+		return out
+	}
+	for name, entry := range file.Connection.Connections {
+		if entry.URI == "" {
+			continue
+		}
+		connection := newEngineConnection(name, "podman", "podman-connections", entry.URI)
+		connection.IdentityFile = entry.Identity
+		connection.IsMachine = entry.IsMachine
+		connection.IsDefault = name == file.Connection.Default
+		out = append(out, connection)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// parseContainersConfConnections reads the connection subset of containers.conf (TOML) — NOT a general TOML
+// parser, like parseSSHConfig is not a general ssh_config one. Understood forms:
+//
+//	[engine]
+//	active_service = "x"
+//	[engine.service_destinations.x]        (also a quoted name: [engine.service_destinations."my host"])
+//	uri = "ssh://…"
+//	identity = "…"
+//	[engine.service_destinations]
+//	x = { uri = "ssh://…", identity = "…" }
+func parseContainersConfConnections(contents string) []EngineConnection {
+	type destination struct {
+		uri, identity string
+		isMachine     bool
+	}
+	active := ""
+	order := []string{}
+	destinations := map[string]*destination{}
+	get := func(name string) *destination {
+		if entry, ok := destinations[name]; ok {
+			return entry
+		}
+		entry := &destination{}
+		destinations[name] = entry
+		order = append(order, name)
+		return entry
+	}
+	apply := func(entry *destination, key, value string) {
+		switch key {
+		case "uri":
+			entry.uri = tomlString(value)
+		case "identity":
+			entry.identity = tomlString(value)
+		case "is_machine":
+			entry.isMachine = strings.TrimSpace(value) == "true"
+		}
+	}
+
+	section := ""
+	for rawLine := range strings.SplitSeq(contents, "\n") {
+		line := strings.TrimSpace(stripTOMLComment(rawLine))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(strings.Trim(line, "[]"))
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch {
+		case section == "engine" && key == "active_service":
+			active = tomlString(value)
+		case section == "engine.service_destinations" && strings.HasPrefix(value, "{"):
+			entry := get(tomlString(key))
+			for _, field := range tomlSplit(strings.Trim(value, "{}"), ',') {
+				if fieldKey, fieldValue, found := tomlCut(field, '='); found {
+					apply(entry, strings.TrimSpace(fieldKey), strings.TrimSpace(fieldValue))
+				}
+			}
+		case strings.HasPrefix(section, "engine.service_destinations."):
+			apply(get(tomlString(strings.TrimPrefix(section, "engine.service_destinations."))), key, value)
+		}
+	}
+
+	out := []EngineConnection{}
+	for _, name := range order {
+		entry := destinations[name]
+		if entry.uri == "" {
+			continue
+		}
+		connection := newEngineConnection(name, "podman", "containers.conf", entry.uri)
+		connection.IdentityFile = entry.identity
+		connection.IsMachine = entry.isMachine
+		connection.IsDefault = name == active
+		out = append(out, connection)
+	}
+	return out
+}
+
+// stripTOMLComment drops a trailing # comment that is not inside a string.
+func stripTOMLComment(line string) string {
+	before, _, _ := tomlCut(line, '#')
+	return before
+}
+
+// tomlSplit splits at every sep outside a string (an inline table's fields at ",").
+func tomlSplit(value string, sep byte) []string {
+	parts := []string{}
+	for {
+		before, after, found := tomlCut(value, sep)
+		parts = append(parts, before)
+		if !found {
+			return parts
+		}
+		value = after
+	}
+}
+
+// tomlCut is strings.Cut at the first sep that is not inside a basic ("…", with \" escapes) or literal ('…')
+// string.
+func tomlCut(line string, sep byte) (before, after string, found bool) {
+	var quote byte
+	for index := 0; index < len(line); index++ {
+		char := line[index]
+		switch {
+		case quote == '"' && char == '\\':
+			index++
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case char == '"' || char == '\'':
+			quote = char
+		case char == sep:
+			return line[:index], line[index+1:], true
+		}
+	}
+	return line, "", false
+}
+
+// tomlString unquotes a basic ("…") or literal ('…') TOML string; a bare key/value is returned trimmed.
+func tomlString(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+		return value[1 : len(value)-1]
+	}
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// newEngineConnection splits the URI into the normalized fields. An unparsable URI is kept verbatim (the picker
+// still shows it; the connect attempt then reports the real error).
+func newEngineConnection(name, engine, source, uri string) EngineConnection {
+	connection := EngineConnection{Name: name, Engine: engine, Source: source, URI: uri}
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return connection
+	}
+	connection.Scheme = parsed.Scheme
+	switch parsed.Scheme {
+	case "unix":
+		connection.Path = parsed.Path
+	case "npipe":
+		connection.Path = stripSocketScheme(uri)
+	default:
+		connection.Host = parsed.Hostname()
+		connection.User = parsed.User.Username()
+		connection.Path = parsed.Path
+		if port, portErr := strconv.ParseUint(parsed.Port(), 10, 32); portErr == nil {
+			connection.Port = uint32(port)
+		} else if parsed.Scheme == "ssh" {
+			connection.Port = 22
+		}
+	}
+	return connection
+}
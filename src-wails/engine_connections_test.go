@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseDockerContextMetaWithTLS(t *testing.T) {
+	tlsDir := t.TempDir()
+	for _, name := range []string{"ca.pem", "cert.pem", "key.pem"} {
+		if err := os.WriteFile(filepath.Join(tlsDir, name), []byte("pem"), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	meta := `{"Name":"prod","Metadata":{"Description":"build farm"},"Endpoints":{"docker":{"Host":"tcp://10.0.0.5:2376","SkipTLSVerify":false}}}`
+	connection, ok := parseDockerContextMeta([]byte(meta), tlsDir)
+	if !ok {
+		t.Fatal("meta.json not parsed")
+	}
+	if connection.Name != "prod" || connection.Engine != "docker" || connection.Scheme != "tcp" || connection.Host != "10.0.0.5" || connection.Port != 2376 {
+		t.Errorf("unexpected connection: %+v", connection)
+	}
+	if connection.TLS == nil || connection.TLS.CAFile != filepath.Join(tlsDir, "ca.pem") || connection.TLS.KeyFile == "" {
+		t.Errorf("tls material not attached: %+v", connection.TLS)
+	}
+	if _, ok := parseDockerContextMeta([]byte(`{"Name":"k8s","Endpoints":{"kubernetes":{}}}`), tlsDir); ok {
+		t.Error("a context without a docker endpoint must be skipped")
+	}
+}
+
+func TestReadDockerContextsMarksCurrent(t *testing.T) {
+	dir := t.TempDir()
+	write := func(rel, contents string) {
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	write("config.json", `{"currentContext":"remote"}`)
+	write("contexts/meta/aaa/meta.json", `{"Name":"remote","Endpoints":{"docker":{"Host":"ssh://deploy@build.example:2222"}}}`)
+	write("contexts/meta/bbb/meta.json", `{"Name":"colima","Endpoints":{"docker":{"Host":"unix:///Users/me/.colima/default/docker.sock"}}}`)
+
+	connections := readDockerContexts(dir)
+	if len(connections) != 2 || connections[0].Name != "colima" || connections[1].Name != "remote" {
+		t.Fatalf("unexpected contexts: %+v", connections)
+	}
+	remote := connections[1]
+	if !remote.IsDefault || remote.User != "deploy" || remote.Host != "build.example" || remote.Port != 2222 || remote.TLS != nil {
+		t.Errorf("unexpected remote: %+v", remote)
+	}
+	if connections[0].IsDefault || connections[0].Path != "/Users/me/.colima/default/docker.sock" {
+		t.Errorf("unexpected colima: %+v", connections[0])
+	}
+}
+
+func TestParsePodmanConnectionsJSON(t *testing.T) {
+	contents := `{"Connection":{"Default":"podman-machine-default","Connections":{
+		"podman-machine-default":{"URI":"ssh://core@127.0.0.1:56789/run/user/501/podman/podman.sock","Identity":"/Users/me/.local/share/containers/podman/machine/machine","IsMachine":true},
+		"lab":{"URI":"ssh://root@lab.example/run/podman/podman.sock","Identity":"/home/me/.ssh/id_ed25519"}}}}`
+	connections := parsePodmanConnectionsJSON([]byte(contents))
+	if len(connections) != 2 || connections[0].Name != "lab" {
+		t.Fatalf("unexpected connections: %+v", connections)
+	}
+	lab, machine := connections[0], connections[1]
+	if lab.Port != 22 || lab.User != "root" || lab.Path != "/run/podman/podman.sock" || lab.IdentityFile != "/home/me/.ssh/id_ed25519" || lab.IsDefault {
+		t.Errorf("unexpected lab: %+v", lab)
+	}
+	if !machine.IsDefault || !machine.IsMachine || machine.Port != 56789 || machine.Source != "podman-connections" {
+		t.Errorf("unexpected machine: %+v", machine)
+	}
+}
+
+func TestParseContainersConfConnections(t *testing.T) {
+	contents := `
+[containers]
+log_driver = "journald"
+
+[engine]
+active_service = "prod" # the default
+
+[engine.service_destinations]
+inline = { uri = "ssh://me@inline.example:2200/run/user/1000/podman/podman.sock", identity = "/home/me/.ssh/inline" }
+commas = { uri = "ssh://me@commas.example/run/podman.sock?opt=a,b", identity = '/home/me/keys/a,b', is_machine = true }
+
+[engine.service_destinations.prod]
+uri = "ssh://root@prod.example/run/podman/podman.sock"
+identity = "/home/me/.ssh/prod#key"
+
+[engine.service_destinations."my box"]
+uri = 'unix:///run/podman/podman.sock#box' # literal string, then a comment
+`
+	connections := parseContainersConfConnections(contents)
+	if len(connections) != 4 {
+		t.Fatalf("unexpected connections: %+v", connections)
+	}
+	inline, commas, prod, box := connections[0], connections[1], connections[2], connections[3]
+	if inline.Name != "inline" || inline.Port != 2200 || inline.IdentityFile != "/home/me/.ssh/inline" || inline.IsDefault {
+		t.Errorf("unexpected inline: %+v", inline)
+	}
+	// Commas inside quoted values do not split the inline table.
+	if commas.URI != "ssh://me@commas.example/run/podman.sock?opt=a,b" || commas.IdentityFile != "/home/me/keys/a,b" || !commas.IsMachine {
+		t.Errorf("unexpected commas: %+v", commas)
+	}
+	if prod.Name != "prod" || !prod.IsDefault || prod.IdentityFile != "/home/me/.ssh/prod#key" || prod.Source != "containers.conf" {
+		t.Errorf("unexpected prod: %+v", prod)
+	}
+	if box.Name != "my box" || box.Scheme != "unix" || box.Path != "/run/podman/podman.sock" || box.URI != "unix:///run/podman/podman.sock#box" {
+		t.Errorf("unexpected box: %+v", box)
+	}
+}
+
+func TestTOMLSplit(t *testing.T) {
+	got := tomlSplit(`a = "x,y", b = 'p,"q', c = 1`, ',')
+	want := []string{`a = "x,y"`, ` b = 'p,"q'`, ` c = 1`}
+	if !slices.Equal(got, want) {
+		t.Errorf("tomlSplit = %q, want %q", got, want)
+	}
+}
+
+func TestStripTOMLComment(t *testing.T) {
+	for line, want := range map[string]string{
+		`key = "value" # note`:     `key = "value" `,
+		`key = "a#b" # note`:       `key = "a#b" `,
+		`key = 'a#b' # note`:       `key = 'a#b' `,
+		`key = 'it"s#' # note`:     `key = 'it"s#' `,
+		`key = "say \"#\"" # note`: `key = "say \"#\"" `,
+		`# whole line`:             ``,
+		`key = 'no comment'`:       `key = 'no comment'`,
+		`key = "a,b" # c,d`:        `key = "a,b" `,
+	} {
+		if got := stripTOMLComment(line); got != want {
+			t.Errorf("stripTOMLComment(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
@@ -3,15 +3,25 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 )
 
+// execWaitDelay bounds how long Execute waits for the output pipes after the child exits or is killed: a
+// grandchild that inherited them (a daemonized helper, an ssh ControlMaster) would otherwise hold Run open.
+const execWaitDelay = 2 * time.Second
+
+// execOutputLimit caps each captured stream when the request sets no limit of its own, so a `podman save` aimed at
+// stdout by mistake truncates instead of exhausting memory.
+const execOutputLimit = 64 << 20
+
 // ExecService is the buffered process primitive + host DNS capability — the Go analog of src-tauri/src/host.rs
 // (command_execute + dns_lookup). The wails invoke shim maps command_execute → Execute, dns_lookup → DNSLookup.
 // Streaming process I/O lives in ProcessService; SSH/WSL bridge I/O in BridgeService/ProxyService.
@@ -28,6 +38,15 @@ type CommandExecuteRequest struct {
 	// Input is piped to the child's stdin (registry `login --password-stdin`, `cat > ca.crt`) so secrets never
 	// appear in argv or logs. Empty string ⇒ no stdin (identical to the prior behavior).
 	Input string `json:"input"`
+	// MaxStdoutBytes / MaxStderrBytes cap what is kept of each stream (0 ⇒ execOutputLimit); the rest is read and
+	// discarded, and the result flagged truncated.
+	MaxStdoutBytes int `json:"maxStdoutBytes,omitempty"`
+	MaxStderrBytes int `json:"maxStderrBytes,omitempty"`
+	// StdoutEncoding "base64" returns stdout as base64 of the raw bytes (image tarballs, archives); "" is text.
+	StdoutEncoding string `json:"stdoutEncoding,omitempty"`
+	// StdoutFile writes stdout straight to that path (relative to Cwd), uncapped; the result's stdout is then empty.
+	// The resolved path is part of what the command policy authorizes, and a failed write fails the result.
+	StdoutFile string `json:"stdoutFile,omitempty"`
 }
 
 // CommandExecutionResult mirrors src-tauri/src/host.rs CommandExecutionResult (and @/env/Types) field-for-field.
@@ -38,13 +57,66 @@ type CommandExecutionResult struct {
 	Stdout  string `json:"stdout"`
 	Stderr  string `json:"stderr"`
 	Command string `json:"command"`
+	// TimedOut is set when timeoutMs ran out; Stdout/Stderr then hold what the child printed before it was killed.
+	TimedOut bool `json:"timedOut,omitempty"`
+	// StdoutTruncated / StderrTruncated are set when the stream went past its byte limit.
+	StdoutTruncated bool `json:"stdoutTruncated,omitempty"`
+	StderrTruncated bool `json:"stderrTruncated,omitempty"`
+	// StdoutEncoding echoes the request's "base64" so callers know to decode.
+	StdoutEncoding string `json:"stdoutEncoding,omitempty"`
+}
+
+// cappedBuffer keeps the first limit bytes written to it and swallows the rest, so the child never blocks on a
+// full pipe.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func newCappedBuffer(limit int) *cappedBuffer {
+	if limit <= 0 {
+		limit = execOutputLimit
+	}
+	return &cappedBuffer{limit: limit}
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); len(p) > room {
+		b.truncated = true
+		b.buf.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	b.buf.Write(p)
+	return len(p), nil
+}
+
+// text is the kept output as a string, less a multi-byte character the limit cut in half.
+func (b *cappedBuffer) text() string {
+	data := b.buf.Bytes()
+	if b.truncated {
+		data = data[:len(data)-incompleteUTF8Suffix(data)]
+	}
+	return string(data)
 }
 
 // Execute runs `launcher args…` to completion and captures stdout/stderr/exit. isolate=true empties the inherited
 // environment before applying Env (sandbox); false layers Env onto it (Command.Execute). timeoutMs>0 caps
-// wall-clock — on timeout the child is killed and a failed result returned. Mirrors host.rs run_command.
+// wall-clock — on timeout the child's whole process group is killed and a failed result returned with the output
+// captured so far. Each stream is capped (maxStdoutBytes/maxStderrBytes); stdout can instead come back as base64
+// or go to stdoutFile. Mirrors host.rs run_command.
 func (s *ExecService) Execute(req CommandExecuteRequest) CommandExecutionResult {
 	command := strings.TrimSpace(req.Launcher + " " + strings.Join(req.Args, " "))
+	output := ""
+	if req.StdoutFile != "" {
+		var err error
+		if output, err = resolveOutputFile(req.StdoutFile, req.Cwd); err != nil {
+			return CommandExecutionResult{Success: false, Stderr: err.Error(), Command: command}
+		}
+	}
+	if err := launchPolicy.authorize(commandRequest{"execute", req.Launcher, req.Args, req.Cwd, output}); err != nil {
+		return CommandExecutionResult{Success: false, Stderr: err.Error(), Command: command}
+	}
 
 	ctx := context.Background()
 	if req.TimeoutMs > 0 {
@@ -66,25 +138,60 @@ func (s *ExecService) Execute(req CommandExecuteRequest) CommandExecutionResult
 		cmd.Env = append(cmd.Env, key+"="+value)
 	}
 	configureHiddenWindow(cmd) // Windows: no console flash per CLI call (build-tagged); no-op elsewhere.
+	// CommandContext would kill only the direct child; take its group (Unix) or tree (Windows) down instead.
+	newProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		deliverSignal(cmd.Process, 9)
+		return nil
+	}
+	cmd.WaitDelay = execWaitDelay
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	stdout, stderr := newCappedBuffer(req.MaxStdoutBytes), newCappedBuffer(req.MaxStderrBytes)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	var file *os.File
+	if output != "" {
+		var err error
+		if file, err = os.Create(output); err != nil {
+			return CommandExecutionResult{Success: false, Stderr: err.Error(), Command: command}
+		}
+		cmd.Stdout = file
+	}
 	// Pipe secret-bearing stdin (registry `login --password-stdin`, `cat > ca.crt`) — never in argv or logs.
 	if req.Input != "" {
 		cmd.Stdin = strings.NewReader(req.Input)
 	}
 	err := cmd.Run()
+	var closeErr error
+	if file != nil {
+		closeErr = file.Close()
+		if cmd.ProcessState == nil {
+			_ = os.Remove(output) // never started: leave no empty file behind
+		}
+	}
 
+	result := CommandExecutionResult{
+		Stdout:          stdout.text(),
+		Stderr:          stderr.text(),
+		Command:         command,
+		StdoutTruncated: stdout.truncated,
+		StderrTruncated: stderr.truncated,
+	}
+	if req.StdoutEncoding == "base64" {
+		result.Stdout = base64.StdEncoding.EncodeToString(stdout.buf.Bytes())
+		result.StdoutEncoding = "base64"
+	}
 	if ctx.Err() == context.DeadlineExceeded {
-		return CommandExecutionResult{
-			Success: false,
-			Stderr:  fmt.Sprintf("command timed out after %dms", req.TimeoutMs),
-			Command: command,
+		// Keep the partial output; the timeout notice goes last on stderr, where callers already look for it.
+		if result.Stderr != "" && !strings.HasSuffix(result.Stderr, "\n") {
+			result.Stderr += "\n"
 		}
+		result.Stderr += fmt.Sprintf("command timed out after %dms", req.TimeoutMs)
+		result.TimedOut = true
+		appendCloseError(&result, closeErr)
+		return result
 	}
 
-	result := CommandExecutionResult{Stdout: stdout.String(), Stderr: stderr.String(), Command: command}
 	if cmd.ProcessState != nil {
 		// ExitCode() is -1 when the child was killed by a signal — map that to null (Rust's Option::None).
 		if code := cmd.ProcessState.ExitCode(); code >= 0 {
@@ -96,9 +203,39 @@ func (s *ExecService) Execute(req CommandExecuteRequest) CommandExecutionResult
 		result.Success = false
 		result.Stderr = err.Error()
 	}
+	appendCloseError(&result, closeErr)
 	return result
 }
 
+// resolveOutputFile is the absolute path stdoutFile names (relative to cwd), with its directory's symlinks
+// followed, so the policy sees where the bytes really go.
+func resolveOutputFile(file, cwd string) (string, error) {
+	path := file
+	if !filepath.IsAbs(path) && cwd != "" {
+		path = filepath.Join(cwd, path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		path = filepath.Join(dir, filepath.Base(path))
+	}
+	return path, nil
+}
+
+// appendCloseError fails a result whose stdoutFile could not be flushed and closed: the output is incomplete.
+func appendCloseError(result *CommandExecutionResult, err error) {
+	if err == nil {
+		return
+	}
+	result.Success = false
+	if result.Stderr != "" && !strings.HasSuffix(result.Stderr, "\n") {
+		result.Stderr += "\n"
+	}
+	result.Stderr += err.Error()
+}
+
 // DNSLookupRequest carries the hostname to resolve.
 type DNSLookupRequest struct {
 	Hostname string `json:"hostname"`
@@ -0,0 +1,122 @@
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A timed-out Execute returns what the child printed before the kill, flagged, with the notice last on stderr.
+func TestExecuteTimeoutKeepsPartialOutput(t *testing.T) {
+	result := (&ExecService{}).Execute(CommandExecuteRequest{
+		Launcher:  "sh",
+		Args:      []string{"-c", "echo out; echo err >&2; sleep 30"},
+		TimeoutMs: 300,
+	})
+	if !result.TimedOut || result.Success || result.Code != nil {
+		t.Fatalf("result = %+v, want a failed, timed-out result", result)
+	}
+	if result.Stdout != "out\n" || result.Stderr != "err\ncommand timed out after 300ms" {
+		t.Errorf("stdout %q, stderr %q", result.Stdout, result.Stderr)
+	}
+}
+
+// The timeout kills the whole process group: a grandchild holding the pipes neither survives nor wedges the call.
+func TestExecuteTimeoutKillsProcessGroup(t *testing.T) {
+	started := time.Now()
+	result := (&ExecService{}).Execute(CommandExecuteRequest{
+		Launcher:  "sh",
+		Args:      []string{"-c", "sleep 30 & echo $!; wait"},
+		TimeoutMs: 300,
+	})
+	if elapsed := time.Since(started); elapsed > execWaitDelay+time.Second {
+		t.Errorf("Execute returned after %v", elapsed)
+	}
+	if !result.TimedOut {
+		t.Fatalf("result = %+v, want timed out", result)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(result.Stdout))
+	if err != nil {
+		t.Fatalf("grandchild pid %q: %v", result.Stdout, err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for pidAlive(pid) && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if pidAlive(pid) {
+		t.Errorf("grandchild %d outlived the timeout", pid)
+	}
+}
+
+// A child that exits on its own but leaves a background grandchild on its pipes returns after execWaitDelay.
+func TestExecuteWaitDelayBoundsLingeringPipes(t *testing.T) {
+	started := time.Now()
+	result := (&ExecService{}).Execute(CommandExecuteRequest{Launcher: "sh", Args: []string{"-c", "echo done; sleep 30 &"}})
+	if elapsed := time.Since(started); elapsed > execWaitDelay+time.Second {
+		t.Errorf("Execute returned after %v, want it bounded by execWaitDelay", elapsed)
+	}
+	if result.TimedOut || !result.Success || result.Stdout != "done\n" {
+		t.Errorf("result = %+v, want the child's own successful exit", result)
+	}
+}
+
+// Each stream keeps at most its limit, never ends in half a character, and the rest is drained rather than
+// blocking the child.
+func TestExecuteOutputCaps(t *testing.T) {
+	result := (&ExecService{}).Execute(CommandExecuteRequest{
+		Launcher:       "sh",
+		Args:           []string{"-c", "printf 'aaé'; head -c 1000000 /dev/zero; echo fine >&2"},
+		MaxStdoutBytes: 3,
+	})
+	if !result.Success || !result.StdoutTruncated || result.StderrTruncated {
+		t.Fatalf("result = %+v, want a successful run with only stdout truncated", result)
+	}
+	if result.Stdout != "aa" || result.Stderr != "fine\n" {
+		t.Errorf("stdout %q, stderr %q", result.Stdout, result.Stderr)
+	}
+}
+
+// Binary stdout round-trips through base64, or lands in a file (relative to cwd) without being captured.
+func TestExecuteBinaryStdout(t *testing.T) {
+	script := `printf '\000\377\376ok'`
+	encoded := (&ExecService{}).Execute(CommandExecuteRequest{Launcher: "sh", Args: []string{"-c", script}, StdoutEncoding: "base64"})
+	if raw, err := base64.StdEncoding.DecodeString(encoded.Stdout); err != nil || !bytes.Equal(raw, []byte("\x00\xff\xfeok")) || encoded.StdoutEncoding != "base64" {
+		t.Errorf("base64 stdout = %+v (%v)", encoded, err)
+	}
+
+	dir := t.TempDir()
+	written := (&ExecService{}).Execute(CommandExecuteRequest{Launcher: "sh", Args: []string{"-c", script}, Cwd: dir, StdoutFile: "out.bin"})
+	data, err := os.ReadFile(filepath.Join(dir, "out.bin"))
+	if !written.Success || written.Stdout != "" || err != nil || !bytes.Equal(data, []byte("\x00\xff\xfeok")) {
+		t.Errorf("stdoutFile run %+v wrote %q (%v)", written, data, err)
+	}
+	missing := (&ExecService{}).Execute(CommandExecuteRequest{Launcher: "true", StdoutFile: filepath.Join(dir, "none", "out")})
+	if missing.Success || missing.Stderr == "" {
+		t.Errorf("unwritable stdoutFile = %+v, want a failure", missing)
+	}
+	unstarted := (&ExecService{}).Execute(CommandExecuteRequest{Launcher: "no-such-launcher", Cwd: dir, StdoutFile: "never.bin"})
+	if unstarted.Success || fileExists(filepath.Join(dir, "never.bin")) {
+		t.Errorf("stdoutFile of a launch that never started = %+v, or it was left behind", unstarted)
+	}
+}
+
+// The stdoutFile's resolved path is part of the authorized request: denyArgs can refuse it, and it is audited.
+func TestExecuteAuthorizesStdoutFile(t *testing.T) {
+	dir := t.TempDir()
+	audit := withLaunchPolicy(t, newCommandPolicy(commandPolicyRules{DenyArgs: []string{`\.bashrc$`}}))
+	refused := (&ExecService{}).Execute(CommandExecuteRequest{Launcher: "echo", Args: []string{"x"}, Cwd: dir, StdoutFile: ".bashrc"})
+	if refused.Success || !strings.Contains(refused.Stderr, "output file") || fileExists(filepath.Join(dir, ".bashrc")) {
+		t.Errorf("denied stdoutFile run = %+v", refused)
+	}
+	entries := readAudit(t, audit)
+	if len(entries) != 1 || entries[0].Decision != "deny" || entries[0].Output != filepath.Join(resolvedDir(t, dir), ".bashrc") {
+		t.Errorf("audit = %+v", entries)
+	}
+}
@@ -4,8 +4,10 @@ go 1.25.0
 
 require (
 	github.com/Microsoft/go-winio v0.6.2
+	github.com/creack/pty v1.1.24
 	github.com/wailsapp/wails/v3 v3.0.0-alpha2.115
 	github.com/zalando/go-keyring v0.2.8
+	golang.org/x/crypto v0.50.0
 )
 
 require (
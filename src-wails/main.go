@@ -36,6 +36,10 @@ func main() {
 	// A relaunched child (ShellService.Relaunch) blocks here until the previous instance has exited, so the
 	// single-instance lock is free before we take it below. A normal launch returns immediately (see relaunch.go).
 	awaitPredecessorExit()
+	// Reap the bridge sockets / ssh children a crashed previous run left behind, before any bridge is ensured.
+	recoverBridges()
+	// Read the user's command policy and start the audit log before any service can launch a child.
+	loadCommandPolicy()
 
 	app := application.New(application.Options{
 		Name:        "Container Desktop",
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/base64"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Output batching: a verbose `podman build` reads faster than one Wails event per 8 KiB read can cross the bridge
+// (each is JSON-encoded and dispatched on its own). Instead, the drains append raw bytes to a per-child batcher.
+// Its flusher emits one "data" event per stream at most every processBatchInterval, or as soon as
+// processBatchBytes are pending. Bytes are concatenated untouched, so `\r` progress redraws arrive as the child
+// wrote them.
+//
+// The flusher, not the drain, does the emitting, so a renderer that cannot keep up never stalls the child. Pending
+// output then grows instead, up to processPendingLimit per stream. Past that the oldest bytes are dropped, like
+// ringBuffer does. The count is reported on the next event of that stream (overflow) and in ProcessInfo.
+
+const (
+	processBatchInterval = 16 * time.Millisecond
+	processBatchBytes    = 64 << 10
+	processPendingLimit  = 1 << 20
+)
+
+type outputBatcher struct {
+	service *ProcessService
+	child   *spawnedProcess
+	kick    chan struct{}
+	closing chan struct{}
+	flushed chan struct{}
+
+	mu      sync.Mutex
+	streams []*pendingOutput // in order of first output since the last flush
+	bytes   int
+	armed   bool
+}
+
+type pendingOutput struct {
+	from     string
+	binary   bool
+	data     []byte
+	overflow uint64
+}
+
+func newOutputBatcher(service *ProcessService, child *spawnedProcess) *outputBatcher {
+	b := &outputBatcher{
+		service: service,
+		child:   child,
+		kick:    make(chan struct{}, 1),
+		closing: make(chan struct{}),
+		flushed: make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// write queues a chunk of the from stream (binary: raw PTY bytes, emitted as base64).
+func (b *outputBatcher) write(from string, binary bool, chunk []byte) {
+	b.child.lastOutput.Store(time.Now().UnixNano())
+	if recorder := b.child.history; recorder != nil {
+		_, _ = recorder.output.Write(chunk)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var stream *pendingOutput
+	for _, candidate := range b.streams {
+		if candidate.from == from {
+			stream = candidate
+		}
+	}
+	if stream == nil {
+		stream = &pendingOutput{from: from, binary: binary}
+		b.streams = append(b.streams, stream)
+	}
+	stream.data = append(stream.data, chunk...)
+	b.bytes += len(chunk)
+	if over := len(stream.data) - processPendingLimit; over > 0 {
+		if !binary {
+			for over < len(stream.data) && !utf8.RuneStart(stream.data[over]) {
+				over++ // never leave half a character at the front
+			}
+		}
+		stream.data = append(stream.data[:0], stream.data[over:]...)
+		stream.overflow += uint64(over)
+		b.child.overflow.Add(uint64(over))
+		b.bytes -= over
+	}
+	switch {
+	case b.bytes >= processBatchBytes:
+		b.signal()
+	case !b.armed:
+		b.armed = true
+		time.AfterFunc(processBatchInterval, b.signal)
+	}
+}
+
+// close flushes what is left, holding nothing back, and stops the flusher. The child's exit is emitted after it.
+func (b *outputBatcher) close() {
+	close(b.closing)
+	<-b.flushed
+}
+
+func (b *outputBatcher) signal() {
+	select {
+	case b.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (b *outputBatcher) run() {
+	defer close(b.flushed)
+	for {
+		select {
+		case <-b.kick:
+			b.flush(false)
+		case <-b.closing:
+			b.flush(true)
+			return
+		}
+	}
+}
+
+// flush emits one event per pending stream. A text stream keeps back a trailing incomplete UTF-8 sequence (JSON
+// would turn it into U+FFFD) until the rest arrives or the final flush.
+func (b *outputBatcher) flush(final bool) {
+	b.mu.Lock()
+	streams := b.streams
+	b.streams, b.bytes, b.armed = nil, 0, false
+	for _, stream := range streams {
+		if final || stream.binary {
+			continue
+		}
+		if keep := incompleteUTF8Suffix(stream.data); keep > 0 {
+			held := &pendingOutput{from: stream.from, data: append([]byte(nil), stream.data[len(stream.data)-keep:]...)}
+			stream.data = stream.data[:len(stream.data)-keep]
+			b.streams = append(b.streams, held)
+			b.bytes += keep
+		}
+	}
+	b.mu.Unlock() // held bytes go out with the stream's next write, which arms the timer again
+	for _, stream := range streams {
+		if len(stream.data) == 0 && stream.overflow == 0 {
+			continue
+		}
+		event := processEvent{Type: "data", From: stream.from, Overflow: stream.overflow}
+		if stream.binary {
+			event.Binary = true
+			event.Data = base64.StdEncoding.EncodeToString(stream.data)
+		} else {
+			event.Data = string(stream.data)
+		}
+		b.service.publish(b.child, event)
+	}
+}
+
+// incompleteUTF8Suffix is the length of a multi-byte character cut off at the end of data (0 when it ends whole).
+func incompleteUTF8Suffix(data []byte) int {
+	for back := 1; back <= utf8.UTFMax && back <= len(data); back++ {
+		if utf8.RuneStart(data[len(data)-back]) {
+			if utf8.FullRune(data[len(data)-back:]) {
+				return 0
+			}
+			return back
+		}
+	}
+	return 0
+}
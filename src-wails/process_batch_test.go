@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// Thousands of tiny \r progress writes arrive as a handful of events, byte for byte.
+func TestProcessOutputIsCoalesced(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	var mu sync.Mutex
+	captured := map[string][]processEvent{}
+	svc := &ProcessService{emit: func(name string, data any) {
+		mu.Lock()
+		defer mu.Unlock()
+		captured[name] = append(captured[name], data.(processEvent))
+	}}
+	script := `i=0; while [ $i -lt 3000 ]; do printf 'progress %d\r' $i; i=$((i+1)); done; echo done`
+	if _, err := svc.Spawn(processSpawnArgs{Payload: SpawnPayload{Launcher: "sh", Args: []string{"-c", script}}, Channel: 51}); err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	var stdout strings.Builder
+	events := 0
+	for _, e := range waitForProcessClose(t, &mu, captured, "stream://51") {
+		if e.Type == "data" {
+			events++
+			stdout.WriteString(e.Data)
+		}
+	}
+	var want strings.Builder
+	for i := range 3000 {
+		fmt.Fprintf(&want, "progress %d\r", i)
+	}
+	want.WriteString("done\n")
+	if stdout.String() != want.String() {
+		t.Errorf("coalesced output differs from what was written (%d vs %d bytes)", stdout.Len(), want.Len())
+	}
+	if events > 300 {
+		t.Errorf("%d data events for 3000 writes, want them coalesced", events)
+	}
+}
+
+// While the renderer is stuck, pending output is capped: the oldest bytes are dropped and counted, and the
+// drains never block.
+func TestOutputBatcherOverflow(t *testing.T) {
+	entered := make(chan struct{}, 1)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var events []processEvent
+	svc := &ProcessService{emit: func(_ string, data any) {
+		select {
+		case entered <- struct{}{}:
+		default:
+		}
+		<-release
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, data.(processEvent))
+	}}
+	child := newSpawnedProcess(SpawnPayload{}, 52)
+	batcher := newOutputBatcher(svc, child)
+
+	batcher.write("stdout", false, []byte("first\n"))
+	<-entered // the flusher is now stuck emitting "first\n"
+	chunk := []byte(strings.Repeat("x", 64<<10))
+	for range 48 { // 3 MiB
+		batcher.write("stdout", false, chunk)
+	}
+	close(release)
+	batcher.close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	var rest strings.Builder
+	var overflow uint64
+	for _, e := range events[1:] {
+		rest.WriteString(e.Data)
+		overflow += e.Overflow
+	}
+	if events[0].Data != "first\n" || rest.Len() != processPendingLimit {
+		t.Errorf("emitted %q then %d bytes, want the first chunk then the newest %d bytes", events[0].Data, rest.Len(), processPendingLimit)
+	}
+	if want := uint64(48*len(chunk) - processPendingLimit); overflow != want || child.overflow.Load() != want {
+		t.Errorf("overflow = %d (child %d), want %d", overflow, child.overflow.Load(), want)
+	}
+}
+
+// A character split across two writes is never emitted in halves.
+func TestOutputBatcherKeepsUTF8Whole(t *testing.T) {
+	var mu sync.Mutex
+	var events []processEvent
+	svc := &ProcessService{emit: func(_ string, data any) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, data.(processEvent))
+	}}
+	batcher := newOutputBatcher(svc, newSpawnedProcess(SpawnPayload{}, 53))
+	batcher.write("stdout", false, []byte("caf\xc3"))
+	time.Sleep(5 * processBatchInterval)
+	batcher.write("stdout", false, []byte("\xa9!"))
+	batcher.close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	var text strings.Builder
+	for _, e := range events {
+		if !utf8.ValidString(e.Data) {
+			t.Errorf("event data %q is not valid UTF-8", e.Data)
+		}
+		text.WriteString(e.Data)
+	}
+	if text.String() != "café!" {
+		t.Errorf("output = %q, want café!", text.String())
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Job history: a child spawned with payload.history is recorded under userData/jobs, one JSON file per job. A
+// failed `podman pull` or `compose up` can then be reviewed after its channel, or the whole app, is gone. The
+// record is written when the job starts and completed when it exits. Args are redacted (redactArgs) and the
+// output kept is its last processHistoryOutputBytes, combined across streams as it was read. A record still
+// "running" that a previous app instance wrote is reported "interrupted". Every processHistoryPruneEvery finishes
+// (starting with the first), the store is trimmed to processHistoryMaxJobs records and those older than
+// processHistoryMaxAge are removed.
+//
+// Pruning never parses a record: the newest come first by file name (the id starts with the start time), a
+// record's age is its file's mtime (the finish for a completed record, the start for an interrupted one), and the
+// jobs this instance is still running are known from activeJobs.
+
+const (
+	processHistoryDir         = "jobs"
+	processHistoryOutputBytes = 256 << 10
+	processHistoryMaxJobs     = 200
+	processHistoryMaxAge      = 30 * 24 * time.Hour
+	processHistoryPruneEvery  = 20
+)
+
+var (
+	// activeJobs are the ids of the records this instance is still writing; pruning leaves them alone.
+	activeJobs   = map[string]bool{}
+	activeJobsMu sync.Mutex
+	// jobFinishes counts completed records, to prune on every processHistoryPruneEvery-th.
+	jobFinishes atomic.Uint64
+)
+
+// JobRecord is one recorded job. Status is running | exited (code 0) | failed | interrupted. List leaves Output
+// out; GetJob includes it. OutputDroppedBytes counts the older output that did not fit.
+type JobRecord struct {
+	ID                 string   `json:"id"`
+	ProcessID          string   `json:"processId"`
+	OwnerPID           int      `json:"ownerPid"`
+	Launcher           string   `json:"launcher"`
+	Args               []string `json:"args"`
+	Cwd                string   `json:"cwd,omitempty"`
+	Status             string   `json:"status"`
+	StartedAt          string   `json:"startedAt"`
+	EndedAt            string   `json:"endedAt,omitempty"`
+	DurationMs         int64    `json:"durationMs,omitempty"`
+	ExitCode           *int     `json:"exitCode,omitempty"`
+	Signal             string   `json:"signal,omitempty"`
+	Output             string   `json:"output,omitempty"`
+	OutputDroppedBytes uint64   `json:"outputDroppedBytes,omitempty"`
+}
+
+type jobIDArgs struct {
+	ID string `json:"id"`
+}
+
+// jobListArgs limits ListJobs to the newest Limit records (0 = all).
+type jobListArgs struct {
+	Limit int `json:"limit"`
+}
+
+// jobPruneArgs removes finished or interrupted records last written more than OlderThanMs ago and/or beyond the
+// newest Keep (either left 0 is not applied).
+type jobPruneArgs struct {
+	OlderThanMs int64 `json:"olderThanMs"`
+	Keep        int   `json:"keep"`
+}
+
+// jobRecorder follows one recorded child.
+type jobRecorder struct {
+	path    string
+	record  JobRecord
+	started time.Time
+	output  *ringBuffer
+}
+
+// ListJobs reports the recorded jobs, newest first, without their output.
+func (s *ProcessService) ListJobs(args jobListArgs) ([]JobRecord, error) {
+	records, err := readJobRecords()
+	if err != nil {
+		return nil, err
+	}
+	if args.Limit > 0 && len(records) > args.Limit {
+		records = records[:args.Limit]
+	}
+	for index := range records {
+		records[index].Output = ""
+	}
+	return records, nil
+}
+
+// GetJob returns one recorded job with its captured output.
+func (s *ProcessService) GetJob(args jobIDArgs) (JobRecord, error) {
+	path, err := jobRecordPath(args.ID)
+	if err != nil {
+		return JobRecord{}, err
+	}
+	record, err := readJobRecord(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return JobRecord{}, errors.New("no recorded job " + args.ID)
+	}
+	return record, err
+}
+
+// PruneJobs deletes finished and interrupted records by age and/or count and reports how many it removed.
+func (s *ProcessService) PruneJobs(args jobPruneArgs) (int, error) {
+	if args.OlderThanMs <= 0 && args.Keep <= 0 {
+		return 0, errors.New("prune: give olderThanMs and/or keep")
+	}
+	return pruneJobRecords(time.Duration(args.OlderThanMs)*time.Millisecond, args.Keep)
+}
+
+// startJobRecorder writes the running record of a child spawned with payload.history (nil when it cannot).
+func startJobRecorder(child *spawnedProcess) *jobRecorder {
+	started := time.Now()
+	id := "job-" + started.UTC().Format("20060102-150405.000") + "-" + child.id
+	path, err := jobRecordPath(id)
+	if err != nil {
+		return nil
+	}
+	recorder := &jobRecorder{
+		path:    path,
+		started: started,
+		output:  newRingBuffer(processHistoryOutputBytes),
+		record: JobRecord{
+			ID:        id,
+			ProcessID: child.id,
+			OwnerPID:  os.Getpid(),
+			Launcher:  child.info.Launcher,
+			Args:      child.info.Args,
+			Cwd:       child.info.Cwd,
+			Status:    "running",
+			StartedAt: started.Format(time.RFC3339),
+		},
+	}
+	setJobActive(id, true)
+	if writeJobRecord(path, recorder.record) != nil {
+		setJobActive(id, false)
+		appendLogLine("WARN", "[jobs] cannot record "+child.id)
+		return nil
+	}
+	return recorder
+}
+
+// finish completes the record from the exit event and, every processHistoryPruneEvery finishes, trims the store.
+func (r *jobRecorder) finish(exit processEvent) {
+	ended := time.Now()
+	r.record.EndedAt = ended.Format(time.RFC3339)
+	r.record.DurationMs = ended.Sub(r.started).Milliseconds()
+	r.record.ExitCode = exit.Code
+	r.record.Signal = exit.Signal
+	r.record.Status = "failed"
+	if exit.Code != nil && *exit.Code == 0 {
+		r.record.Status = "exited"
+	}
+	r.record.Output = string(r.output.Bytes())
+	r.record.OutputDroppedBytes = r.output.Dropped()
+	err := writeJobRecord(r.path, r.record)
+	setJobActive(r.record.ID, false)
+	if err != nil {
+		appendLogLine("WARN", "[jobs] cannot complete "+r.record.ID)
+		return
+	}
+	if jobFinishes.Add(1)%processHistoryPruneEvery == 1 {
+		_, _ = pruneJobRecords(processHistoryMaxAge, processHistoryMaxJobs)
+	}
+}
+
+func setJobActive(id string, active bool) {
+	activeJobsMu.Lock()
+	defer activeJobsMu.Unlock()
+	if active {
+		activeJobs[id] = true
+	} else {
+		delete(activeJobs, id)
+	}
+}
+
+func jobHistoryDir() (string, error) {
+	base, err := userDataPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, processHistoryDir), nil
+}
+
+// jobRecordPath maps a job id to its file, refusing anything that is not a bare job id.
+func jobRecordPath(id string) (string, error) {
+	if !strings.HasPrefix(id, "job-") || filepath.Base(id) != id || strings.ContainsAny(id, `/\`) {
+		return "", errors.New("invalid job id " + id)
+	}
+	dir, err := jobHistoryDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, id+".json"), nil
+}
+
+// writeJobRecord replaces the record through a rename, so a reader never sees half of it.
+func writeJobRecord(path string, record JobRecord) error {
+	contents, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, contents, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(temp, path)
+}
+
+func readJobRecord(path string) (JobRecord, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return JobRecord{}, err
+	}
+	var record JobRecord
+	if err := json.Unmarshal(contents, &record); err != nil {
+		return JobRecord{}, err
+	}
+	if record.Status == "running" && record.OwnerPID != os.Getpid() {
+		record.Status = "interrupted"
+	}
+	return record, nil
+}
+
+// readJobRecords loads every record, newest first; unreadable files are skipped.
+func readJobRecords() ([]JobRecord, error) {
+	dir, err := jobHistoryDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "job-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	records := []JobRecord{}
+	for _, path := range paths {
+		if record, err := readJobRecord(path); err == nil {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID > records[j].ID })
+	return records, nil
+}
+
+// pruneJobRecords removes the records last written before now-olderThan (when > 0) or beyond the newest keep (when
+// > 0), from the directory listing alone. This instance's running records are never removed.
+func pruneJobRecords(olderThan time.Duration, keep int) (int, error) {
+	dir, err := jobHistoryDir()
+	if err != nil {
+		return 0, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	records := []os.DirEntry{}
+	for _, entry := range entries {
+		if name := entry.Name(); strings.HasPrefix(name, "job-") && strings.HasSuffix(name, ".json") {
+			records = append(records, entry)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Name() > records[j].Name() })
+	activeJobsMu.Lock()
+	active := maps.Clone(activeJobs)
+	activeJobsMu.Unlock()
+	removed := 0
+	for index, entry := range records {
+		if active[strings.TrimSuffix(entry.Name(), ".json")] {
+			continue
+		}
+		expired := false
+		if info, err := entry.Info(); olderThan > 0 && err == nil {
+			expired = time.Since(info.ModTime()) > olderThan
+		}
+		if !expired && (keep <= 0 || index < keep) {
+			continue
+		}
+		if os.Remove(filepath.Join(dir, entry.Name())) == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,170 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A job spawned with history is recorded with redacted args, its exit and its output, and survives the child.
+func TestProcessHistoryRecordsJob(t *testing.T) {
+	t.Setenv("CONTAINER_DESKTOP_USER_DATA_DIR", t.TempDir())
+	svc, mu, captured := recordingProcessService()
+	if _, err := svc.Spawn(processSpawnArgs{Payload: SpawnPayload{
+		Launcher: "sh",
+		Args:     []string{"-c", "echo pulling; echo denied >&2; exit 2", "sh", "--password", "hunter2"},
+		History:  true,
+	}, Channel: 71}); err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	waitForProcessClose(t, mu, captured, "stream://71")
+
+	var jobs []JobRecord
+	waitFor(t, "the completed record", func() bool {
+		jobs, _ = svc.ListJobs(jobListArgs{})
+		return len(jobs) == 1 && jobs[0].Status != "running"
+	})
+	job := jobs[0]
+	if job.Status != "failed" || job.ExitCode == nil || *job.ExitCode != 2 || job.EndedAt == "" {
+		t.Errorf("record = %+v, want failed with exit code 2", job)
+	}
+	if slices.Contains(job.Args, "hunter2") || !slices.Contains(job.Args, redactedValue) {
+		t.Errorf("recorded args = %q, want the password redacted", job.Args)
+	}
+	if job.Output != "" {
+		t.Error("ListJobs returned the output")
+	}
+	full, err := svc.GetJob(jobIDArgs{ID: job.ID})
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if !strings.Contains(full.Output, "pulling\n") || !strings.Contains(full.Output, "denied\n") {
+		t.Errorf("recorded output = %q, want both streams", full.Output)
+	}
+	if _, err := svc.GetJob(jobIDArgs{ID: "../" + job.ID}); err == nil {
+		t.Error("a path-like job id was accepted")
+	}
+}
+
+// Only the last processHistoryOutputBytes of output are kept, and the rest is counted.
+func TestProcessHistoryCapsOutput(t *testing.T) {
+	t.Setenv("CONTAINER_DESKTOP_USER_DATA_DIR", t.TempDir())
+	svc, mu, captured := recordingProcessService()
+	if _, err := svc.Spawn(processSpawnArgs{Payload: SpawnPayload{
+		Launcher: "sh",
+		Args:     []string{"-c", "head -c 400000 /dev/zero | tr '\\0' x; echo; echo tail-marker"},
+		History:  true,
+	}, Channel: 72}); err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	waitForProcessClose(t, mu, captured, "stream://72")
+	var jobs []JobRecord
+	waitFor(t, "the completed record", func() bool {
+		jobs, _ = svc.ListJobs(jobListArgs{})
+		return len(jobs) == 1 && jobs[0].Status == "exited"
+	})
+	job, err := svc.GetJob(jobIDArgs{ID: jobs[0].ID})
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if len(job.Output) != processHistoryOutputBytes || !strings.HasSuffix(job.Output, "tail-marker\n") {
+		t.Errorf("kept %d bytes (tail %q), want the last %d", len(job.Output), job.Output[len(job.Output)-12:], processHistoryOutputBytes)
+	}
+	if job.OutputDroppedBytes != uint64(400000+len("\ntail-marker\n")-processHistoryOutputBytes) {
+		t.Errorf("dropped = %d", job.OutputDroppedBytes)
+	}
+}
+
+// A record left running by another app instance reads as interrupted; pruning goes by age (the record's mtime, so
+// an interrupted record ages out too) and count, and never touches this instance's running jobs.
+func TestProcessHistoryPruneAndInterrupted(t *testing.T) {
+	t.Setenv("CONTAINER_DESKTOP_USER_DATA_DIR", t.TempDir())
+	svc := &ProcessService{emit: func(string, any) {}}
+	old := time.Now().Add(-48 * time.Hour)
+	recent := time.Now().Add(-time.Minute)
+	for _, record := range []JobRecord{
+		{ID: "job-20260101-000000.000-proc-1", Status: "exited", EndedAt: old.Format(time.RFC3339)},
+		{ID: "job-20260102-000000.000-proc-1", Status: "running", OwnerPID: 1},
+		{ID: "job-20260103-000000.000-proc-1", Status: "failed", EndedAt: recent.Format(time.RFC3339)},
+		{ID: "job-20260104-000000.000-proc-1", Status: "exited", EndedAt: recent.Format(time.RFC3339)},
+		{ID: "job-20260105-000000.000-proc-1", Status: "running", OwnerPID: os.Getpid()},
+	} {
+		path, err := jobRecordPath(record.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writeJobRecord(path, record); err != nil {
+			t.Fatal(err)
+		}
+		written := recent
+		if record.EndedAt == old.Format(time.RFC3339) || record.OwnerPID == 1 {
+			written = old
+		}
+		if err := os.Chtimes(path, written, written); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setJobActive("job-20260105-000000.000-proc-1", true)
+	defer setJobActive("job-20260105-000000.000-proc-1", false)
+	jobs, err := svc.ListJobs(jobListArgs{Limit: 4})
+	if err != nil || len(jobs) != 4 || jobs[0].ID != "job-20260105-000000.000-proc-1" {
+		t.Fatalf("list = %+v, %v; want the newest 4, newest first", jobs, err)
+	}
+	if jobs[3].Status != "interrupted" {
+		t.Errorf("another instance's running record reads %q, want interrupted", jobs[3].Status)
+	}
+
+	if removed, err := svc.PruneJobs(jobPruneArgs{OlderThanMs: time.Hour.Milliseconds()}); err != nil || removed != 2 {
+		t.Errorf("prune by age removed %d (%v), want the old finished and the old interrupted record", removed, err)
+	}
+	if removed, err := svc.PruneJobs(jobPruneArgs{Keep: 2}); err != nil || removed != 1 {
+		t.Errorf("prune by count removed %d (%v), want the 1 beyond the newest 2", removed, err)
+	}
+	jobs, _ = svc.ListJobs(jobListArgs{})
+	if len(jobs) != 2 || jobs[0].Status != "running" {
+		t.Errorf("left = %+v, want this instance's running job and the newest finished one", jobs)
+	}
+	if _, err := svc.PruneJobs(jobPruneArgs{}); err == nil {
+		t.Error("prune without criteria succeeded")
+	}
+}
+
+// Finishing a job only trims the store every processHistoryPruneEvery finishes, and then by age as well as count.
+func TestProcessHistoryPrunesPeriodically(t *testing.T) {
+	t.Setenv("CONTAINER_DESKTOP_USER_DATA_DIR", t.TempDir())
+	stale, err := jobRecordPath("job-20250101-000000.000-proc-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeJobRecord(stale, JobRecord{ID: "job-20250101-000000.000-proc-1", Status: "running", OwnerPID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	expired := time.Now().Add(-processHistoryMaxAge - time.Hour)
+	if err := os.Chtimes(stale, expired, expired); err != nil {
+		t.Fatal(err)
+	}
+	finishJob := func(seq int) {
+		recorder := startJobRecorder(&spawnedProcess{id: "proc-" + strconv.Itoa(seq), info: ProcessInfo{Launcher: "true"}})
+		if recorder == nil {
+			t.Fatal("no recorder")
+		}
+		code := 0
+		recorder.finish(processEvent{Type: "exit", Code: &code})
+	}
+
+	jobFinishes.Store(1)
+	finishJob(2)
+	if !fileExists(stale) {
+		t.Fatal("a finish between prunes pruned")
+	}
+	jobFinishes.Store(processHistoryPruneEvery)
+	finishJob(3)
+	if fileExists(stale) {
+		t.Error("the periodic prune kept an interrupted record past processHistoryMaxAge")
+	}
+}
@@ -0,0 +1,106 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Kill takes a shell wrapper's whole process group down: the backgrounded grandchild dies with it, and the
+// stream closes instead of waiting on the pipe the grandchild held.
+func TestProcessKillTerminatesGroup(t *testing.T) {
+	svc, mu, captured := recordingProcessService()
+	res, err := svc.Spawn(processSpawnArgs{
+		Payload: SpawnPayload{Launcher: "sh", Args: []string{"-c", "sleep 30 & echo $!; wait"}},
+		Channel: 21,
+	})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	var grandchild int
+	waitFor(t, "the grandchild pid", func() bool {
+		grandchild, err = strconv.Atoi(strings.TrimSpace(streamedStdout(mu, captured, "stream://21")))
+		return err == nil
+	})
+	svc.Kill(processKillArgs{Payload: KillPayload{ProcessID: res.ProcessID}})
+
+	for _, e := range waitForProcessClose(t, mu, captured, "stream://21") {
+		if e.Type == "exit" && e.Signal != "SIGTERM" {
+			t.Errorf("exit signal = %q, want SIGTERM", e.Signal)
+		}
+	}
+	waitFor(t, "the grandchild to exit", func() bool { return !pidAlive(grandchild) })
+}
+
+// With a grace period, a child that ignores SIGTERM is SIGKILLed once it runs out, and the exit event says so.
+func TestProcessKillEscalates(t *testing.T) {
+	svc, mu, captured := recordingProcessService()
+	res, err := svc.Spawn(processSpawnArgs{
+		Payload: SpawnPayload{Launcher: "sh", Args: []string{"-c", "trap '' TERM; echo ready; while :; do sleep 0.1; done"}},
+		Channel: 22,
+	})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	waitFor(t, "the TERM trap", func() bool { return streamedStdout(mu, captured, "stream://22") != "" })
+	svc.Kill(processKillArgs{Payload: KillPayload{ProcessID: res.ProcessID, Signal: "SIGTERM", GraceMs: 200}})
+
+	var exit *processEvent
+	for _, e := range waitForProcessClose(t, mu, captured, "stream://22") {
+		if e.Type == "exit" {
+			exit = &e
+		}
+	}
+	if exit == nil || exit.Signal != "SIGKILL" || !exit.Escalated || exit.Code != nil {
+		t.Errorf("exit = %+v, want SIGKILL after escalation and no code", exit)
+	}
+}
+
+// A child that honours SIGTERM within the grace period is not escalated.
+func TestProcessKillGracefulWithinGrace(t *testing.T) {
+	svc, mu, captured := recordingProcessService()
+	res, err := svc.Spawn(processSpawnArgs{Payload: SpawnPayload{Launcher: "sleep", Args: []string{"30"}}, Channel: 23})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	svc.Kill(processKillArgs{Payload: KillPayload{ProcessID: res.ProcessID, GraceMs: 5000}})
+	for _, e := range waitForProcessClose(t, mu, captured, "stream://23") {
+		if e.Type == "exit" && (e.Signal != "SIGTERM" || e.Escalated) {
+			t.Errorf("exit = %+v, want SIGTERM without escalation", e)
+		}
+	}
+}
+
+func recordingProcessService() (*ProcessService, *sync.Mutex, map[string][]processEvent) {
+	mu := &sync.Mutex{}
+	captured := map[string][]processEvent{}
+	svc := &ProcessService{emit: func(name string, data any) {
+		mu.Lock()
+		defer mu.Unlock()
+		captured[name] = append(captured[name], data.(processEvent))
+	}}
+	return svc, mu, captured
+}
+
+func streamedStdout(mu *sync.Mutex, captured map[string][]processEvent, event string) string {
+	mu.Lock()
+	defer mu.Unlock()
+	var stdout string
+	for _, e := range captured[event] {
+		if e.Type == "data" && e.From == "stdout" {
+			stdout += e.Data
+		}
+	}
+	return stdout
+}
+
+// pidAlive reports a live process; a zombie awaiting its (re)parent's reap counts as gone.
+func pidAlive(pid int) bool {
+	output, err := exec.Command("ps", "-o", "stat=", "-p", strconv.Itoa(pid)).Output()
+	state := strings.TrimSpace(string(output))
+	return err == nil && state != "" && !strings.HasPrefix(state, "Z")
+}
@@ -4,11 +4,51 @@ package main
 
 import (
 	"os"
+	"os/exec"
+	"strconv"
 	"syscall"
 )
 
-// deliverSignal sends a POSIX signal to the process (Linux/macOS) — the analog of process.rs deliver_signal's
-// libc::kill arm. A no-op error (e.g. ESRCH when the child already exited) is ignored.
+// deliverSignal sends a POSIX signal to the child's whole process group (Linux/macOS), so a `podman-compose up`
+// or shell wrapper takes its grandchildren (compose, conmon helpers, ssh) down with it. Spawned children lead
+// their own group (newProcessGroup, or the PTY's session); when the group cannot be signalled the process itself
+// is — the analog of process.rs deliver_signal's libc::kill arm. A no-op error (e.g. ESRCH when the child already
+// exited) is ignored.
 func deliverSignal(process *os.Process, sig int) {
-	_ = process.Signal(syscall.Signal(sig))
+	if syscall.Kill(-process.Pid, syscall.Signal(sig)) != nil {
+		_ = process.Signal(syscall.Signal(sig))
+	}
+}
+
+// newProcessGroup starts the child as the leader of a new process group (pgid = pid) for deliverSignal. It also
+// keeps a terminal's Ctrl-C, when the app was launched from one, from reaching the child.
+func newProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// exitSignal names the signal that terminated the child ("SIGKILL"), or "" when it exited on its own.
+func exitSignal(state *os.ProcessState) string {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return ""
+	}
+	switch sig := status.Signal(); sig {
+	case syscall.SIGHUP:
+		return "SIGHUP"
+	case syscall.SIGINT:
+		return "SIGINT"
+	case syscall.SIGQUIT:
+		return "SIGQUIT"
+	case syscall.SIGKILL:
+		return "SIGKILL"
+	case syscall.SIGPIPE:
+		return "SIGPIPE"
+	case syscall.SIGTERM:
+		return "SIGTERM"
+	default:
+		return strconv.Itoa(int(sig))
+	}
 }
@@ -10,9 +10,17 @@ import (
 
 // deliverSignal terminates the process tree by pid on Windows — there are no POSIX signals, so the number is
 // ignored (mirrors process.rs deliver_signal's taskkill arm). configureHiddenWindow keeps taskkill's own console
-// from flashing.
+// from flashing. taskkill runs in the background but is waited for, so its process handle is released.
 func deliverSignal(process *os.Process, _ int) {
 	cmd := exec.Command("taskkill", "/F", "/T", "/PID", strconv.Itoa(process.Pid))
 	configureHiddenWindow(cmd)
-	_ = cmd.Start()
+	go func() { _ = cmd.Run() }()
+}
+
+// newProcessGroup is a no-op on Windows: taskkill /T already walks the tree.
+func newProcessGroup(_ *exec.Cmd) {}
+
+// exitSignal is always "" on Windows: a terminated process reports an exit code.
+func exitSignal(_ *os.ProcessState) string {
+	return ""
 }
@@ -0,0 +1,83 @@
+//go:build !windows
+
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// A PTY child sees a terminal of the requested size, gets Write input as keystrokes (echoed by the line
+// discipline), follows Resize, and streams raw bytes as base64 "pty" data.
+func TestProcessSpawnPTY(t *testing.T) {
+	var mu sync.Mutex
+	captured := map[string][]processEvent{}
+	svc := &ProcessService{emit: func(name string, data any) {
+		mu.Lock()
+		defer mu.Unlock()
+		captured[name] = append(captured[name], data.(processEvent))
+	}}
+	output := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		var text strings.Builder
+		for _, e := range captured["stream://9"] {
+			if e.Type == "data" {
+				if e.From != "pty" || !e.Binary {
+					t.Errorf("pty data event = %+v, want from pty, binary", e)
+				}
+				decoded, _ := base64.StdEncoding.DecodeString(e.Data)
+				text.Write(decoded)
+			}
+		}
+		return text.String()
+	}
+
+	script := "stty size; read line; echo got:$line; stty size"
+	res, err := svc.Spawn(processSpawnArgs{
+		Payload: SpawnPayload{Launcher: "sh", Args: []string{"-c", script}, Pty: true, Cols: 100, Rows: 30},
+		Channel: 9,
+	})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	waitFor(t, "initial terminal size", func() bool { return strings.Contains(output(), "30 100") })
+	if err := svc.Resize(processResizeArgs{Payload: ProcessResizePayload{ProcessID: res.ProcessID, Cols: 120, Rows: 40}}); err != nil {
+		t.Fatalf("resize: %v", err)
+	}
+	if err := svc.Write(processWriteArgs{Payload: ProcessWritePayload{ProcessID: res.ProcessID, Data: "hello\r"}}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	events := waitForProcessClose(t, &mu, captured, "stream://9")
+	text := output()
+	if !strings.Contains(text, "got:hello") || !strings.Contains(text, "40 120") {
+		t.Errorf("pty output = %q, want the input echoed back and the resized terminal", text)
+	}
+	for _, e := range events {
+		if e.Type == "exit" && (e.Code == nil || *e.Code != 0) {
+			t.Errorf("exit code = %v, want 0", e.Code)
+		}
+	}
+	if err := svc.Write(processWriteArgs{Payload: ProcessWritePayload{ProcessID: res.ProcessID, Data: "x"}}); err == nil {
+		t.Error("write to an exited process succeeded")
+	}
+}
+
+// Write/Resize refuse a pipe-mode child: it has no terminal.
+func TestProcessWriteRequiresPTY(t *testing.T) {
+	svc := &ProcessService{emit: func(string, any) {}}
+	res, err := svc.Spawn(processSpawnArgs{Payload: SpawnPayload{Launcher: "sleep", Args: []string{"10"}}, Channel: 10})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	defer svc.Kill(processKillArgs{Payload: KillPayload{ProcessID: res.ProcessID, Signal: "SIGKILL"}})
+	if err := svc.Write(processWriteArgs{Payload: ProcessWritePayload{ProcessID: res.ProcessID, Data: "x"}}); err == nil || !strings.Contains(err.Error(), "pty") {
+		t.Errorf("write error = %v, want a not-a-pty error", err)
+	}
+	if err := svc.Resize(processResizeArgs{Payload: ProcessResizePayload{ProcessID: res.ProcessID, Cols: 80, Rows: 24}}); err == nil {
+		t.Error("resize of a pipe-mode process succeeded")
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/creack/pty"
+)
+
+// startPTY starts cmd on a new pseudo-terminal of the given size (the child gets its own session with the PTY as
+// controlling terminal, so job control and `-it` engines work) and returns the master side.
+func startPTY(cmd *exec.Cmd, cols, rows uint16) (*os.File, error) {
+	return pty.StartWithSize(cmd, &pty.Winsize{Cols: cols, Rows: rows})
+}
+
+// resizePTY sets the terminal size; the kernel sends the foreground process group SIGWINCH.
+func resizePTY(master *os.File, cols, rows uint16) error {
+	return pty.Setsize(master, &pty.Winsize{Cols: cols, Rows: rows})
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+var errPTYUnsupported = errors.New("pty spawn is supported on Linux and macOS only")
+
+func startPTY(_ *exec.Cmd, _, _ uint16) (*os.File, error) {
+	return nil, errPTYUnsupported
+}
+
+func resizePTY(_ *os.File, _, _ uint16) error {
+	return errPTYUnsupported
+}
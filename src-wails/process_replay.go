@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Output replay: a renderer reload (the recovery flow, a dev reload) drops the channels its children stream to,
+// while the children themselves keep running in ProcessService. Each child therefore keeps its most recent
+// output — whole events, so the order the renderer saw and the exit/close pair survive — up to
+// processReplayBytes of data, dropping the oldest chunks first like ringBuffer drops the oldest bytes. The
+// reloaded renderer finds its children with List and re-attaches each with Attach, which replays the log to the
+// new channel and moves the live stream there.
+//
+// A child that finishes while no renderer is attached would otherwise take its exit with it, so a spawned child
+// (not a supervised service's, which reports through the service) stays listed once finished — Finished set, its
+// log ending in exit and close — for processFinishedTTL, and only the newest processFinishedMax of them.
+
+const (
+	processReplayBytes = 512 << 10
+	processFinishedMax = 32
+	processFinishedTTL = 10 * time.Minute
+)
+
+// ProcessInfo is one registered child. Args are redacted (redactArgs), as in the bridge inventory; Channel is
+// the channel currently attached; BufferedBytes/DroppedBytes describe the replay log, OverflowBytes the output
+// dropped before it was ever emitted (process_batch.go). FinishedAt is set once the child has exited.
+type ProcessInfo struct {
+	ProcessID     string   `json:"processId"`
+	Pid           int      `json:"pid"`
+	Launcher      string   `json:"launcher"`
+	Args          []string `json:"args"`
+	Cwd           string   `json:"cwd,omitempty"`
+	Pty           bool     `json:"pty,omitempty"`
+	Stdin         bool     `json:"stdin,omitempty"`
+	History       bool     `json:"history,omitempty"`
+	StartedAt     string   `json:"startedAt"`
+	Channel       uint64   `json:"channel"`
+	BufferedBytes int      `json:"bufferedBytes"`
+	DroppedBytes  uint64   `json:"droppedBytes"`
+	OverflowBytes uint64   `json:"overflowBytes"`
+	Finished      bool     `json:"finished,omitempty"`
+	FinishedAt    string   `json:"finishedAt,omitempty"`
+}
+
+type processAttachArgs struct {
+	Payload ProcessAttachPayload `json:"payload"`
+	// The new channel, as in processSpawnArgs.
+	Channel uint64 `json:"channel"`
+}
+
+// ProcessAttachPayload names the child to re-attach.
+type ProcessAttachPayload struct {
+	ProcessID string `json:"processId"`
+}
+
+// outputLog is a child's replay log, bounded by the bytes of event data it holds. The newest event is always
+// kept, even when it alone is over the bound.
+type outputLog struct {
+	events  []processEvent
+	bytes   int
+	dropped uint64
+}
+
+func (l *outputLog) add(event processEvent) {
+	l.events = append(l.events, event)
+	l.bytes += len(event.Data)
+	for l.bytes > processReplayBytes && len(l.events) > 1 {
+		l.bytes -= len(l.events[0].Data)
+		l.dropped += uint64(len(l.events[0].Data))
+		l.events[0] = processEvent{} // release the chunk before the backing array is reallocated
+		l.events = l.events[1:]
+	}
+}
+
+// List reports the running children and the retained finished ones, oldest first.
+func (s *ProcessService) List() []ProcessInfo {
+	s.mu.Lock()
+	s.expireFinishedLocked(time.Now())
+	children := make([]*spawnedProcess, 0, len(s.children)+len(s.finished))
+	for _, child := range s.children {
+		children = append(children, child)
+	}
+	children = append(children, s.finished...)
+	s.mu.Unlock()
+	sort.Slice(children, func(i, j int) bool { return children[i].seq < children[j].seq })
+	out := make([]ProcessInfo, 0, len(children))
+	for _, child := range children {
+		child.eventsMu.Lock()
+		out = append(out, child.infoLocked())
+		child.eventsMu.Unlock()
+	}
+	return out
+}
+
+// Attach moves a child's stream to a new channel: the replay log is emitted there first (each event marked
+// replay), then live events follow with nothing lost or repeated in between. The previous channel gets no more
+// events. A child that finished, before or while being attached, replays through its exit and close.
+func (s *ProcessService) Attach(args processAttachArgs) (ProcessInfo, error) {
+	child, err := s.listed(args.Payload.ProcessID)
+	if err != nil {
+		return ProcessInfo{}, err
+	}
+	child.eventsMu.Lock()
+	defer child.eventsMu.Unlock()
+	child.channel = args.Channel
+	name := streamEventName(args.Channel)
+	for _, event := range child.output.events {
+		event.Replay = true
+		s.emitProcess(name, event)
+	}
+	return child.infoLocked(), nil
+}
+
+// infoLocked snapshots the child's info (eventsMu held).
+func (p *spawnedProcess) infoLocked() ProcessInfo {
+	info := p.info
+	info.Channel = p.channel
+	info.BufferedBytes = p.output.bytes
+	info.DroppedBytes = p.output.dropped
+	info.OverflowBytes = p.overflow.Load()
+	return info
+}
+
+// retire moves a reaped child from the registry to the finished list, or just drops it when it is a service's.
+func (s *ProcessService) retire(child *spawnedProcess) {
+	now := time.Now()
+	child.eventsMu.Lock()
+	child.info.Finished = true
+	child.info.FinishedAt = now.Format(time.RFC3339)
+	child.eventsMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.children, child.id)
+	if child.observe != nil {
+		return
+	}
+	child.finishedAt = now
+	s.finished = append(s.finished, child)
+	if excess := len(s.finished) - processFinishedMax; excess > 0 {
+		clear(s.finished[:excess])
+		s.finished = s.finished[excess:]
+	}
+	s.expireFinishedLocked(now)
+}
+
+// expireFinishedLocked drops the finished children retained past processFinishedTTL (s.mu held).
+func (s *ProcessService) expireFinishedLocked(now time.Time) {
+	expired := 0
+	for expired < len(s.finished) && now.Sub(s.finished[expired].finishedAt) > processFinishedTTL {
+		expired++
+	}
+	clear(s.finished[:expired])
+	s.finished = s.finished[expired:]
+}
+
+// listed finds a running or retained finished child.
+func (s *ProcessService) listed(processID string) (*spawnedProcess, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if child, ok := s.children[processID]; ok {
+		return child, nil
+	}
+	s.expireFinishedLocked(time.Now())
+	for _, child := range s.finished {
+		if child.id == processID {
+			return child, nil
+		}
+	}
+	return nil, errors.New("process " + processID + " does not exist")
+}
@@ -0,0 +1,146 @@
+//go:build !windows
+
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A re-attached child replays what it printed so far to the new channel, then streams live there and no longer
+// to the old one.
+func TestProcessAttachReplaysThenStreamsLive(t *testing.T) {
+	svc, mu, captured := recordingProcessService()
+	res, err := svc.Spawn(processSpawnArgs{
+		Payload: SpawnPayload{
+			Launcher: "sh",
+			Args:     []string{"-c", "echo one; echo two >&2; read line; echo three", "sh", "--token=abc"},
+			Stdin:    true,
+		},
+		Channel: 31,
+	})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	waitFor(t, "the first output", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(captured["stream://31"]) == 2
+	})
+
+	list := svc.List()
+	if len(list) != 1 || list[0].ProcessID != res.ProcessID || list[0].Channel != 31 || list[0].BufferedBytes != len("one\ntwo\n") {
+		t.Fatalf("list = %+v, want the child on channel 31 with its output buffered", list)
+	}
+	if !slices.Contains(list[0].Args, "--token="+redactedValue) {
+		t.Errorf("listed args = %q, want the token redacted", list[0].Args)
+	}
+
+	info, err := svc.Attach(processAttachArgs{Payload: ProcessAttachPayload{ProcessID: res.ProcessID}, Channel: 32})
+	if err != nil {
+		t.Fatalf("attach: %v", err)
+	}
+	if info.Channel != 32 {
+		t.Errorf("attached channel = %d, want 32", info.Channel)
+	}
+	if err := svc.WriteStdin(processWriteArgs{Payload: ProcessWritePayload{ProcessID: res.ProcessID, Data: "go\n"}}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	events := waitForProcessClose(t, mu, captured, "stream://32")
+
+	var replayed, live []string
+	for _, e := range events {
+		if e.Type != "data" {
+			continue
+		}
+		if e.Replay {
+			replayed = append(replayed, e.From+":"+e.Data)
+		} else {
+			live = append(live, e.From+":"+e.Data)
+		}
+	}
+	slices.Sort(replayed) // the two pipes are drained independently, so their relative order is not fixed
+	if strings.Join(replayed, "") != "stderr:two\nstdout:one\n" {
+		t.Errorf("replayed = %q, want the earlier stdout and stderr", replayed)
+	}
+	if strings.Join(live, "") != "stdout:three\n" {
+		t.Errorf("live = %q, want the output after attaching", live)
+	}
+	mu.Lock()
+	old := len(captured["stream://31"])
+	mu.Unlock()
+	if old != 2 {
+		t.Errorf("old channel got %d events, want only the 2 before attaching", old)
+	}
+	waitFor(t, "the child to be listed as finished", func() bool {
+		list := svc.List()
+		return len(list) == 1 && list[0].Finished && list[0].FinishedAt != ""
+	})
+	// A finished child stays attachable: the reloaded renderer still gets its output, exit and close.
+	if _, err := svc.Attach(processAttachArgs{Payload: ProcessAttachPayload{ProcessID: res.ProcessID}, Channel: 33}); err != nil {
+		t.Fatalf("attach to the finished child: %v", err)
+	}
+	mu.Lock()
+	replay := captured["stream://33"]
+	mu.Unlock()
+	if len(replay) < 2 || replay[len(replay)-2].Type != "exit" || replay[len(replay)-1].Type != "close" || !replay[0].Replay {
+		t.Errorf("replay of the finished child = %+v, want its log through exit and close", replay)
+	}
+}
+
+// Finished children are retained up to processFinishedMax, newest kept, and for processFinishedTTL.
+func TestFinishedProcessesBounded(t *testing.T) {
+	svc := &ProcessService{emit: func(string, any) {}}
+	ids := []string{}
+	// One at a time, so they finish in spawn order and ids[0] is the oldest.
+	for range processFinishedMax + 3 {
+		res, err := svc.Spawn(processSpawnArgs{Payload: SpawnPayload{Launcher: "true"}, Channel: 34})
+		if err != nil {
+			t.Fatalf("spawn: %v", err)
+		}
+		ids = append(ids, res.ProcessID)
+		waitFor(t, "the child to finish", func() bool {
+			svc.mu.Lock()
+			defer svc.mu.Unlock()
+			return len(svc.children) == 0
+		})
+	}
+	list := svc.List()
+	if len(list) != processFinishedMax {
+		t.Fatalf("listed %d finished children, want %d", len(list), processFinishedMax)
+	}
+	if _, err := svc.Attach(processAttachArgs{Payload: ProcessAttachPayload{ProcessID: ids[0]}, Channel: 35}); err == nil {
+		t.Error("attached to a child past the retention count")
+	}
+
+	svc.mu.Lock()
+	for _, child := range svc.finished[:4] {
+		child.finishedAt = time.Now().Add(-processFinishedTTL - time.Second)
+	}
+	svc.mu.Unlock()
+	if got := len(svc.List()); got != processFinishedMax-4 {
+		t.Errorf("listed %d finished children after 4 expired, want %d", got, processFinishedMax-4)
+	}
+}
+
+// The replay log drops the oldest chunks past its bound, counting them, and always keeps the newest.
+func TestOutputLogBounded(t *testing.T) {
+	var log outputLog
+	chunk := strings.Repeat("x", processReplayBytes/4)
+	for range 6 {
+		log.add(processEvent{Type: "data", Data: chunk})
+	}
+	if log.bytes != processReplayBytes || len(log.events) != 4 || log.dropped != uint64(2*len(chunk)) {
+		t.Errorf("log holds %d bytes in %d events, dropped %d", log.bytes, len(log.events), log.dropped)
+	}
+	log.add(processEvent{Type: "data", Data: strings.Repeat("y", 2*processReplayBytes)})
+	if len(log.events) != 1 || log.events[0].Data[0] != 'y' {
+		t.Errorf("an oversized chunk left %d events, want just itself", len(log.events))
+	}
+	log.add(processEvent{Type: "close"})
+	if last := log.events[len(log.events)-1]; last.Type != "close" {
+		t.Errorf("last event = %q, want close", last.Type)
+	}
+}
@@ -1,23 +1,41 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
+// ptyDrainGrace bounds how long a PTY child's output is drained after it exits.
+const ptyDrainGrace = 200 * time.Millisecond
+
 // ProcessService is the streaming process port — the Go side of ICommand.ExecuteStreaming +
 // ExecuteAsBackgroundService (and the kill registry), the analog of src-tauri/src/process.rs. ONE command,
 // process_spawn, backs both (the difference is JS-side in exec/commander.ts). A spawned child streams its raw
 // stdout/stderr/exit/close events to "stream://<channel>" over Wails Events (text — no binary), and a registry
 // lets the renderer kill it by a generated processId token.
+//
+// With payload.pty (Linux/macOS), the child runs on a pseudo-terminal instead of pipes, for interactive commands
+// (`podman machine ssh`, `docker exec -it`, shells) rendered by an embedded xterm.js: its output is one "pty"
+// stream of raw terminal bytes (base64 with binary:true, like the proxy's log frames), Write sends keystrokes, and
+// Resize follows the terminal's cols/rows. With payload.stdin, a pipe-mode child's stdin is a pipe the renderer
+// writes with WriteStdin and ends with CloseStdin (process_stdin.go); otherwise it reads from the null device.
+// Every child keeps a bounded replay log of its events, so a reloaded renderer can List its children and Attach
+// to them again (process_replay.go).
 type ProcessService struct {
 	mu       sync.Mutex
-	children map[string]*os.Process
+	children map[string]*spawnedProcess
+	// finished are reaped children kept for List and Attach, oldest first (process_replay.go).
+	finished []*spawnedProcess
+	services map[string]*supervisor
 	counter  atomic.Uint64
 	// emit: test override, else the live Wails app emitter (see events.go).
 	emit func(name string, data any)
@@ -30,6 +48,55 @@ type SpawnPayload struct {
 	Cwd      string   `json:"cwd"`
 	// Overrides layered onto the inherited parent env (matches the TS-side merge).
 	Env map[string]string `json:"env"`
+	// Pty runs the child on a pseudo-terminal of Cols×Rows (default 80×24) — Linux/macOS only.
+	Pty  bool   `json:"pty,omitempty"`
+	Cols uint16 `json:"cols,omitempty"`
+	Rows uint16 `json:"rows,omitempty"`
+	// Stdin opens a pipe to the child's stdin for WriteStdin/CloseStdin (pipe mode; a PTY always takes input).
+	Stdin bool `json:"stdin,omitempty"`
+	// StatsIntervalMs > 0 samples the child's resource use (process_stats.go) — Linux only.
+	StatsIntervalMs int `json:"statsIntervalMs,omitempty"`
+	// History records the job under userData/jobs (process_history.go).
+	History bool `json:"history,omitempty"`
+	// Isolate starts the child from an empty environment instead of the parent's (as ExecService.Execute);
+	// InheritEnv, when set, is the allow-list of parent variables it keeps ("LC_*" matches a prefix), isolated or
+	// not. Env is layered on top either way.
+	Isolate    bool     `json:"isolate,omitempty"`
+	InheritEnv []string `json:"inheritEnv,omitempty"`
+	// TimeoutMs caps the wall-clock run, IdleTimeoutMs the time without output (process_timeout.go).
+	TimeoutMs     uint64 `json:"timeoutMs,omitempty"`
+	IdleTimeoutMs uint64 `json:"idleTimeoutMs,omitempty"`
+}
+
+// spawnedProcess is one registry entry; pty is the PTY master for a PTY-mode child (nil for pipes), stdin the
+// pipe of a child spawned with payload.stdin.
+type spawnedProcess struct {
+	process *os.Process
+	pty     *os.File
+	stdin   *childStdin
+	// exited closes once the child is reaped; escalated records that Kill's grace ran out and SIGKILL followed.
+	exited    chan struct{}
+	escalated atomic.Bool
+
+	id   string
+	seq  uint64
+	info ProcessInfo
+	// finishedAt is when it was retired to ProcessService.finished (s.mu).
+	finishedAt time.Time
+	// eventsMu orders every event of the child against Attach: channel is where they go, output what Attach
+	// replays (process_replay.go).
+	eventsMu sync.Mutex
+	channel  uint64
+	output   outputLog
+	observe  func(processEvent)
+	// batcher coalesces the output into data events (process_batch.go); overflow counts the bytes it dropped.
+	batcher  *outputBatcher
+	overflow atomic.Uint64
+	sampler  *statsSampler
+	history  *jobRecorder
+	// lastOutput (unix nanos) feeds the idle timeout; timedOut is the kind of timeout that fired, if any.
+	lastOutput atomic.Int64
+	timedOut   atomic.Value
 }
 
 type processSpawnArgs struct {
@@ -48,21 +115,62 @@ type processKillArgs struct {
 	Payload KillPayload `json:"payload"`
 }
 
-// KillPayload mirrors the Tauri KillPayload ({ processId, signal? }).
+// KillPayload mirrors the Tauri KillPayload ({ processId, signal? }). GraceMs > 0 escalates: when the child has
+// not exited that long after Signal, its group gets SIGKILL.
 type KillPayload struct {
 	ProcessID string `json:"processId"`
 	Signal    string `json:"signal"`
+	GraceMs   int    `json:"graceMs,omitempty"`
+}
+
+type processWriteArgs struct {
+	Payload ProcessWritePayload `json:"payload"`
+}
+
+// ProcessWritePayload is terminal input for a PTY-mode child: Data as typed, or base64 when Binary is set.
+type ProcessWritePayload struct {
+	ProcessID string `json:"processId"`
+	Data      string `json:"data"`
+	Binary    bool   `json:"binary,omitempty"`
+}
+
+type processResizeArgs struct {
+	Payload ProcessResizePayload `json:"payload"`
+}
+
+// ProcessResizePayload is the terminal size for a PTY-mode child.
+type ProcessResizePayload struct {
+	ProcessID string `json:"processId"`
+	Cols      uint16 `json:"cols"`
+	Rows      uint16 `json:"rows"`
 }
 
 // processEvent mirrors exec/process-utils.ts ProcessEventMessage: data → {from,data}; exit → {code,signal};
-// close → {code}; error → {errorType,error}.
+// close → {code}; error → {errorType,error}. A PTY child's data is from "pty", base64 with binary:true. exit's
+// signal is the one that ended the child ("" when it exited on its own), escalated that Kill had to SIGKILL it.
 type processEvent struct {
 	ProcessID string `json:"processId"`
 	Type      string `json:"type"`
 	From      string `json:"from,omitempty"`
 	Data      string `json:"data,omitempty"`
+	Binary    bool   `json:"binary,omitempty"`
+	// Overflow is how many bytes of this stream were dropped before this data because the renderer fell behind.
+	Overflow  uint64 `json:"overflow,omitempty"`
 	Code      *int   `json:"code,omitempty"`
 	Signal    string `json:"signal,omitempty"`
+	Escalated bool   `json:"escalated,omitempty"`
+	// A supervised service's "state" event (process_supervisor.go): the new state, why, and the restarts so far.
+	ServiceID string `json:"serviceId,omitempty"`
+	State     string `json:"state,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	Restarts  int    `json:"restarts,omitempty"`
+	// Timeout is the deadline that ended the child ("wall" | "idle"), on its "timeout" event and its exit.
+	Timeout string `json:"timeout,omitempty"`
+	// Stats is a "stats" sample; Usage the exit summary of a sampled child (process_stats.go).
+	Stats *ProcessStats `json:"stats,omitempty"`
+	Usage *ProcessUsage `json:"usage,omitempty"`
+	// Replay marks an event Attach re-emits from the replay log.
+	Replay    bool   `json:"replay,omitempty"`
 	ErrorType string `json:"errorType,omitempty"`
 	Error     string `json:"error,omitempty"`
 }
@@ -71,70 +179,194 @@ type processEvent struct {
 // Returns the processId token + pid immediately (before the process finishes). Mirrors process.rs process_spawn.
 func (s *ProcessService) Spawn(args processSpawnArgs) (SpawnResult, error) {
 	payload := args.Payload
+	if err := launchPolicy.authorize(commandRequest{"spawn", payload.Launcher, payload.Args, payload.Cwd, ""}); err != nil {
+		return SpawnResult{}, err
+	}
+	child, err := s.start(payload, args.Channel, nil)
+	if err != nil {
+		return SpawnResult{}, err
+	}
+	return child.spawnResult(), nil
+}
+
+// start spawns and registers a child streaming to channel; observe, when set, sees each of its events as it is
+// published (the supervisor's hook — it must not block).
+func (s *ProcessService) start(payload SpawnPayload, channel uint64, observe func(processEvent)) (*spawnedProcess, error) {
+	entry := newSpawnedProcess(payload, channel)
+	entry.observe = observe
 	cmd := exec.Command(payload.Launcher, payload.Args...)
 	if payload.Cwd != "" {
 		cmd.Dir = payload.Cwd
 	}
-	cmd.Env = os.Environ()
-	for key, value := range payload.Env {
-		cmd.Env = append(cmd.Env, key+"="+value)
-	}
+	cmd.Env = spawnEnv(payload)
 	configureHiddenWindow(cmd) // Windows: no console flash for a streamed child (build-tagged); no-op else.
+	if payload.Pty {
+		// The PTY child leads its own session (and so its process group) already.
+		return entry, s.spawnPTY(cmd, entry, payload)
+	}
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return SpawnResult{}, err
+		return nil, err
 	}
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return SpawnResult{}, err
+		return nil, err
+	}
+	newProcessGroup(cmd) // Unix: Kill signals the whole group (process_kill_unix.go); no-op on Windows.
+	if payload.Stdin {
+		pipe, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		entry.stdin = &childStdin{pipe: pipe}
 	}
 	if err := cmd.Start(); err != nil {
-		return SpawnResult{}, err
+		return nil, err
 	}
 
-	pid := cmd.Process.Pid
-	processID := fmt.Sprintf("proc-%d", s.counter.Add(1))
-	eventName := fmt.Sprintf("stream://%d", args.Channel)
-	s.register(processID, cmd.Process)
+	s.launched(entry, cmd.Process, payload)
 
 	// Drain both pipes concurrently; wait for BOTH to hit EOF before cmd.Wait() (Go closes the pipes on Wait, so
 	// reading after Wait would race — the documented StdoutPipe/StderrPipe ordering).
 	var drained sync.WaitGroup
 	drained.Add(2)
-	go func() { defer drained.Done(); s.drain(stdout, "stdout", eventName, processID) }()
-	go func() { defer drained.Done(); s.drain(stderr, "stderr", eventName, processID) }()
+	go func() { defer drained.Done(); s.drain(stdout, "stdout", entry) }()
+	go func() { defer drained.Done(); s.drain(stderr, "stderr", entry) }()
 	go func() {
 		drained.Wait()
 		waitErr := cmd.Wait()
-		code := exitCode(cmd, waitErr)
-		s.emitProcess(eventName, processEvent{ProcessID: processID, Type: "exit", Code: code})
-		s.emitProcess(eventName, processEvent{ProcessID: processID, Type: "close", Code: code})
-		s.unregister(processID)
+		close(entry.exited)
+		entry.batcher.close()
+		s.emitExit(entry, cmd, waitErr)
+		s.retire(entry)
 	}()
 
-	pidValue := pid
-	return SpawnResult{ProcessID: processID, Pid: &pidValue}, nil
+	return entry, nil
 }
 
-// Kill signals a registered process by token (default SIGTERM; SIGKILL/SIGINT accepted). No-op if it already
-// exited. Signal delivery is platform-specific (process_kill_{unix,windows}.go). Mirrors process.rs process_kill.
+// Kill signals a registered process by token (default SIGTERM; SIGKILL/SIGINT accepted) — on Unix its whole
+// process group. With GraceMs, a child still running after the grace period is SIGKILLed (the graceful
+// SIGTERM-then-SIGKILL stop); the exit event reports the signal that ended it. No-op if it already exited.
+// Signal delivery is platform-specific (process_kill_{unix,windows}.go). Mirrors process.rs process_kill.
 func (s *ProcessService) Kill(args processKillArgs) {
 	s.mu.Lock()
-	process, ok := s.children[args.Payload.ProcessID]
+	child, ok := s.children[args.Payload.ProcessID]
 	s.mu.Unlock()
 	if ok {
-		deliverSignal(process, parseSignal(args.Payload.Signal))
+		child.terminate(parseSignal(args.Payload.Signal), time.Duration(args.Payload.GraceMs)*time.Millisecond)
+	}
+}
+
+// terminate signals the child and, with a grace period, SIGKILLs it when it outlives the grace.
+func (p *spawnedProcess) terminate(sig int, grace time.Duration) {
+	deliverSignal(p.process, sig)
+	if grace <= 0 {
+		return
 	}
+	go func() {
+		select {
+		case <-p.exited:
+		case <-time.After(grace):
+			p.escalated.Store(true)
+			deliverSignal(p.process, 9)
+		}
+	}()
 }
 
-func (s *ProcessService) drain(reader io.Reader, from, eventName, processID string) {
+// spawnPTY starts the child on a pseudo-terminal and streams the master side as raw bytes. The PTY stays open
+// until the child has exited and its output is drained (bounded by ptyDrainGrace, since a background grandchild
+// can hold the terminal open indefinitely).
+func (s *ProcessService) spawnPTY(cmd *exec.Cmd, entry *spawnedProcess, payload SpawnPayload) error {
+	cols, rows := payload.Cols, payload.Rows
+	if cols == 0 || rows == 0 {
+		cols, rows = 80, 24
+	}
+	cmd.Env = append(cmd.Env, "TERM=xterm-256color")
+	master, err := startPTY(cmd, cols, rows)
+	if err != nil {
+		return err
+	}
+	entry.pty = master
+	s.launched(entry, cmd.Process, payload)
+
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		buf := make([]byte, 8192)
+		for {
+			n, readErr := master.Read(buf)
+			if n > 0 {
+				entry.batcher.write("pty", true, buf[:n])
+			}
+			if readErr != nil {
+				return // EIO once the last slave fd closes (Linux), or EOF
+			}
+		}
+	}()
+	go func() {
+		waitErr := cmd.Wait()
+		close(entry.exited)
+		select {
+		case <-drained:
+		case <-time.After(ptyDrainGrace):
+		}
+		s.unregister(entry.id)
+		_ = master.Close()
+		<-drained
+		entry.batcher.close()
+		s.emitExit(entry, cmd, waitErr)
+		s.retire(entry)
+	}()
+
+	return nil
+}
+
+// Write sends input to a PTY-mode child (keystrokes from the embedded terminal).
+func (s *ProcessService) Write(args processWriteArgs) error {
+	master, err := s.ptyFor(args.Payload.ProcessID)
+	if err != nil {
+		return err
+	}
+	data, err := args.Payload.bytes()
+	if err != nil {
+		return err
+	}
+	_, err = master.Write(data)
+	return stdinError(args.Payload.ProcessID, err)
+}
+
+// Resize sets a PTY-mode child's terminal size (the child receives SIGWINCH).
+func (s *ProcessService) Resize(args processResizeArgs) error {
+	if args.Payload.Cols == 0 || args.Payload.Rows == 0 {
+		return errors.New("resize: cols and rows must be positive")
+	}
+	master, err := s.ptyFor(args.Payload.ProcessID)
+	if err != nil {
+		return err
+	}
+	return resizePTY(master, args.Payload.Cols, args.Payload.Rows)
+}
+
+func (s *ProcessService) ptyFor(processID string) (*os.File, error) {
+	child, err := s.running(processID)
+	if err != nil {
+		return nil, err
+	}
+	if child.pty == nil {
+		return nil, errors.New("process " + processID + " was not spawned with a pty")
+	}
+	return child.pty, nil
+}
+
+func (s *ProcessService) drain(reader io.Reader, from string, child *spawnedProcess) {
 	// Raw chunks (not line-split) to mirror Node's stream "data" — preserves \r progress updates in build output.
+	// The batcher coalesces them into events (process_batch.go).
 	buf := make([]byte, 8192)
 	for {
 		n, err := reader.Read(buf)
 		if n > 0 {
-			s.emitProcess(eventName, processEvent{ProcessID: processID, Type: "data", From: from, Data: string(buf[:n])})
+			child.batcher.write(from, false, buf[:n])
 		}
 		if err != nil {
 			return // EOF or read error → this pipe is done
@@ -142,19 +374,141 @@ func (s *ProcessService) drain(reader io.Reader, from, eventName, processID stri
 	}
 }
 
+// emitExit reports a reaped child: exit (code, the ending signal, escalation) then close.
+func (s *ProcessService) emitExit(child *spawnedProcess, cmd *exec.Cmd, waitErr error) {
+	code := exitCode(cmd, waitErr)
+	exit := processEvent{Type: "exit", Code: code, Escalated: child.escalated.Load()}
+	exit.Timeout, _ = child.timedOut.Load().(string)
+	if cmd.ProcessState != nil {
+		exit.Signal = exitSignal(cmd.ProcessState)
+		if child.sampler != nil {
+			exit.Usage = child.sampler.summary(cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime())
+		}
+	}
+	s.publish(child, exit)
+	s.publish(child, processEvent{Type: "close", Code: code})
+	if child.history != nil {
+		child.history.finish(exit)
+	}
+}
+
+// publish records an event in the child's replay log and emits it to the channel currently attached.
+func (s *ProcessService) publish(child *spawnedProcess, event processEvent) {
+	event.ProcessID = child.id
+	child.eventsMu.Lock()
+	defer child.eventsMu.Unlock()
+	child.output.add(event)
+	s.emitProcess(streamEventName(child.channel), event)
+	if child.observe != nil {
+		child.observe(event)
+	}
+}
+
+// emitLive emits an event to the child's current channel without recording it for replay.
+func (s *ProcessService) emitLive(child *spawnedProcess, event processEvent) {
+	event.ProcessID = child.id
+	child.eventsMu.Lock()
+	defer child.eventsMu.Unlock()
+	s.emitProcess(streamEventName(child.channel), event)
+}
+
 func (s *ProcessService) emitProcess(name string, event processEvent) {
 	emitToRenderer(s.emit, name, event)
 }
 
-func (s *ProcessService) register(id string, process *os.Process) {
+// launched sets up a started child: registration, output batching, and the opt-in sampler and deadlines.
+func (s *ProcessService) launched(child *spawnedProcess, process *os.Process, payload SpawnPayload) {
+	s.admit(child, process)
+	child.batcher = newOutputBatcher(s, child)
+	child.sampler = s.startStatsSampler(child, payload.StatsIntervalMs)
+	s.watchTimeouts(child, payload.TimeoutMs, payload.IdleTimeoutMs)
+}
+
+// admit gives a started child its processId token and registers it.
+func (s *ProcessService) admit(child *spawnedProcess, process *os.Process) {
+	child.seq = s.counter.Add(1)
+	child.id = fmt.Sprintf("proc-%d", child.seq)
+	child.process = process
+	child.info.ProcessID = child.id
+	child.info.Pid = process.Pid
+	child.info.StartedAt = time.Now().Format(time.RFC3339)
+	if child.info.History {
+		child.history = startJobRecorder(child)
+	}
 	s.mu.Lock()
 	if s.children == nil {
-		s.children = map[string]*os.Process{}
+		s.children = map[string]*spawnedProcess{}
 	}
-	s.children[id] = process
+	s.children[child.id] = child
 	s.mu.Unlock()
 }
 
+func newSpawnedProcess(payload SpawnPayload, channel uint64) *spawnedProcess {
+	return &spawnedProcess{
+		exited:  make(chan struct{}),
+		channel: channel,
+		info: ProcessInfo{
+			Launcher: payload.Launcher,
+			Args:     redactArgs(payload.Args),
+			Cwd:      payload.Cwd,
+			Pty:      payload.Pty,
+			Stdin:    payload.Stdin,
+			History:  payload.History,
+		},
+	}
+}
+
+func (p *spawnedProcess) spawnResult() SpawnResult {
+	pid := p.process.Pid
+	return SpawnResult{ProcessID: p.id, Pid: &pid}
+}
+
+// spawnEnv is the child's environment: the parent's (or none, isolated), narrowed to InheritEnv when given, with
+// Env layered on top.
+func spawnEnv(payload SpawnPayload) []string {
+	env := []string{}
+	if !payload.Isolate || len(payload.InheritEnv) > 0 {
+		for _, entry := range os.Environ() {
+			name, _, _ := strings.Cut(entry, "=")
+			if len(payload.InheritEnv) == 0 || envAllowed(name, payload.InheritEnv) {
+				env = append(env, entry)
+			}
+		}
+	}
+	for key, value := range payload.Env {
+		env = append(env, key+"="+value)
+	}
+	return env
+}
+
+// envAllowed matches a variable name against the allow-list; names are case-insensitive on Windows.
+func envAllowed(name string, allowed []string) bool {
+	for _, pattern := range allowed {
+		prefix, wildcard := strings.CutSuffix(pattern, "*")
+		switch {
+		case runtime.GOOS == "windows" && wildcard:
+			if len(name) >= len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+				return true
+			}
+		case runtime.GOOS == "windows":
+			if strings.EqualFold(name, pattern) {
+				return true
+			}
+		case wildcard:
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		case name == pattern:
+			return true
+		}
+	}
+	return false
+}
+
+func streamEventName(channel uint64) string {
+	return fmt.Sprintf("stream://%d", channel)
+}
+
 func (s *ProcessService) unregister(id string) {
 	s.mu.Lock()
 	delete(s.children, id)
@@ -98,8 +98,7 @@ func TestProcessKill(t *testing.T) {
 		captured[name] = append(captured[name], data.(processEvent))
 	}}
 
-	// Spawn `sleep` DIRECTLY (not `sh -c`): the app streams engine binaries directly, and killing a shell's pid
-	// would leave the forked child holding the pipe (process.rs kills by pid too — no process-group semantics).
+	// Spawn `sleep` DIRECTLY, as the app streams engine binaries (a shell wrapper's tree is process_kill_test.go's).
 	res, err := svc.Spawn(processSpawnArgs{
 		Payload: SpawnPayload{Launcher: "sleep", Args: []string{"10"}},
 		Channel: 8,
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Resource sampling, opt-in per child with payload.statsIntervalMs: which long build or compose run is eating
+// the host. Every interval the child and its descendants are summed from /proc (Linux; elsewhere sampling is
+// off) and a "stats" event goes to its channel. Stats events are live only and never enter the replay log. The
+// exit event then carries a usage summary: peaks from the samples, and the CPU time the wait status reports
+// (which, unlike a sample, includes descendants that finished between samples).
+
+const processStatsMinInterval = 250 * time.Millisecond
+
+// ProcessStats is one sample of a child's process tree. CPUTimeMs includes the reaped children of each member;
+// CPUPercent is the tree's CPU use since the previous sample (100 = one core). ReadBytes/WriteBytes are storage
+// I/O (/proc/<pid>/io read_bytes/write_bytes) of the live members.
+type ProcessStats struct {
+	Processes  int     `json:"processes"`
+	Threads    int     `json:"threads"`
+	CPUTimeMs  uint64  `json:"cpuTimeMs"`
+	CPUPercent float64 `json:"cpuPercent"`
+	RSSBytes   uint64  `json:"rssBytes"`
+	ReadBytes  uint64  `json:"readBytes"`
+	WriteBytes uint64  `json:"writeBytes"`
+	SampledAt  string  `json:"sampledAt"`
+}
+
+// ProcessUsage is the exit event's summary of a sampled child.
+type ProcessUsage struct {
+	CPUTimeMs     uint64 `json:"cpuTimeMs"`
+	PeakRSSBytes  uint64 `json:"peakRssBytes"`
+	PeakProcesses int    `json:"peakProcesses"`
+	PeakThreads   int    `json:"peakThreads"`
+	ReadBytes     uint64 `json:"readBytes"`
+	WriteBytes    uint64 `json:"writeBytes"`
+	Samples       int    `json:"samples"`
+}
+
+type statsSampler struct {
+	done chan struct{}
+
+	mu    sync.Mutex
+	usage ProcessUsage
+}
+
+// startStatsSampler samples the child every interval until it exits; nil when sampling is off or unsupported.
+func (s *ProcessService) startStatsSampler(child *spawnedProcess, intervalMs int) *statsSampler {
+	if intervalMs <= 0 || !processStatsSupported {
+		return nil
+	}
+	interval := max(time.Duration(intervalMs)*time.Millisecond, processStatsMinInterval)
+	sampler := &statsSampler{done: make(chan struct{})}
+	go func() {
+		defer close(sampler.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var previous ProcessStats
+		var previousAt time.Time
+		for {
+			select {
+			case <-child.exited:
+				return
+			case <-ticker.C:
+			}
+			stats, err := sampleProcessTree(child.process.Pid)
+			if err != nil {
+				continue // the child is exiting
+			}
+			now := time.Now()
+			if !previousAt.IsZero() && stats.CPUTimeMs > previous.CPUTimeMs {
+				stats.CPUPercent = float64(stats.CPUTimeMs-previous.CPUTimeMs) / float64(now.Sub(previousAt).Milliseconds()) * 100
+			}
+			stats.SampledAt = now.Format(time.RFC3339Nano)
+			previous, previousAt = stats, now
+			sampler.record(stats)
+			s.emitLive(child, processEvent{Type: "stats", Stats: &stats})
+		}
+	}()
+	return sampler
+}
+
+func (p *statsSampler) record(stats ProcessStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.usage.Samples++
+	p.usage.CPUTimeMs = max(p.usage.CPUTimeMs, stats.CPUTimeMs)
+	p.usage.PeakRSSBytes = max(p.usage.PeakRSSBytes, stats.RSSBytes)
+	p.usage.PeakProcesses = max(p.usage.PeakProcesses, stats.Processes)
+	p.usage.PeakThreads = max(p.usage.PeakThreads, stats.Threads)
+	p.usage.ReadBytes = max(p.usage.ReadBytes, stats.ReadBytes)
+	p.usage.WriteBytes = max(p.usage.WriteBytes, stats.WriteBytes)
+}
+
+// summary waits for the sampler to stop (the child has exited) and folds in the wait status's CPU time.
+func (p *statsSampler) summary(cpu time.Duration) *ProcessUsage {
+	<-p.done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	usage := p.usage
+	usage.CPUTimeMs = max(usage.CPUTimeMs, uint64(cpu.Milliseconds()))
+	return &usage
+}
@@ -0,0 +1,119 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const processStatsSupported = true
+
+// clockTicksPerSecond is USER_HZ, the unit of /proc/<pid>/stat CPU times — 100 on every Linux ABI Go supports.
+const clockTicksPerSecond = 100
+
+// procStat is the part of /proc/<pid>/stat the sampler uses.
+type procStat struct {
+	ppid    int
+	ticks   uint64 // utime + stime + cutime + cstime
+	threads int
+	rss     uint64 // pages
+}
+
+// sampleProcessTree sums the stats of pid and every live descendant (found through their parent pids).
+func sampleProcessTree(pid int) (ProcessStats, error) {
+	root, err := readProcStat(pid)
+	if err != nil {
+		return ProcessStats{}, err
+	}
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return ProcessStats{}, err
+	}
+	children := map[int][]int{}
+	stats := map[int]procStat{pid: root}
+	for _, entry := range entries {
+		candidate, err := strconv.Atoi(entry.Name())
+		if err != nil || candidate == pid {
+			continue
+		}
+		stat, err := readProcStat(candidate)
+		if err != nil {
+			continue // exited since the listing
+		}
+		stats[candidate] = stat
+		children[stat.ppid] = append(children[stat.ppid], candidate)
+	}
+
+	var sample ProcessStats
+	var ticks uint64
+	pageSize := uint64(os.Getpagesize())
+	for queue := []int{pid}; len(queue) > 0; queue = queue[1:] {
+		member := queue[0]
+		stat := stats[member]
+		sample.Processes++
+		sample.Threads += stat.threads
+		sample.RSSBytes += stat.rss * pageSize
+		ticks += stat.ticks
+		read, write := readProcIO(member)
+		sample.ReadBytes += read
+		sample.WriteBytes += write
+		queue = append(queue, children[member]...)
+	}
+	sample.CPUTimeMs = ticks * 1000 / clockTicksPerSecond
+	return sample, nil
+}
+
+func readProcStat(pid int) (procStat, error) {
+	raw, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return procStat{}, err
+	}
+	// The command name (field 2) may contain spaces and parentheses; the fields after its last ')' are fixed.
+	end := bytes.LastIndexByte(raw, ')')
+	if end < 0 {
+		return procStat{}, errors.New("malformed stat for pid " + strconv.Itoa(pid))
+	}
+	fields := strings.Fields(string(raw[end+1:]))
+	// fields[0] is field 3 (state): ppid is field 4, utime..cstime 14..17, num_threads 20, rss 24.
+	if len(fields) < 22 {
+		return procStat{}, errors.New("short stat for pid " + strconv.Itoa(pid))
+	}
+	number := func(field int) uint64 {
+		value, _ := strconv.ParseUint(fields[field-3], 10, 64)
+		return value
+	}
+	return procStat{
+		ppid:    int(number(4)),
+		ticks:   number(14) + number(15) + number(16) + number(17),
+		threads: int(number(20)),
+		rss:     number(24),
+	}, nil
+}
+
+// readProcIO returns read_bytes/write_bytes from /proc/<pid>/io (zero when it cannot be read).
+func readProcIO(pid int) (read, write uint64) {
+	file, err := os.Open("/proc/" + strconv.Itoa(pid) + "/io")
+	if err != nil {
+		return 0, 0
+	}
+	defer func() { _ = file.Close() }()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), ": ")
+		if !ok {
+			continue
+		}
+		switch name {
+		case "read_bytes":
+			read, _ = strconv.ParseUint(value, 10, 64)
+		case "write_bytes":
+			write, _ = strconv.ParseUint(value, 10, 64)
+		}
+	}
+	return read, write
+}
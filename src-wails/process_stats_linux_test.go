@@ -0,0 +1,69 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// A sampled child streams stats covering its descendants, and its exit carries the usage summary.
+func TestProcessStatsSampling(t *testing.T) {
+	svc, mu, captured := recordingProcessService()
+	script := `sleep 0.8 & pid=$!; while kill -0 $pid 2>/dev/null; do :; done`
+	if _, err := svc.Spawn(processSpawnArgs{
+		Payload: SpawnPayload{Launcher: "sh", Args: []string{"-c", script}, StatsIntervalMs: 100},
+		Channel: 61,
+	}); err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	var samples []ProcessStats
+	var usage *ProcessUsage
+	for _, e := range waitForProcessClose(t, mu, captured, "stream://61") {
+		switch e.Type {
+		case "stats":
+			samples = append(samples, *e.Stats)
+		case "exit":
+			usage = e.Usage
+		}
+	}
+	if len(samples) == 0 {
+		t.Fatal("no stats events")
+	}
+	peak := 0
+	for _, sample := range samples {
+		peak = max(peak, sample.Processes)
+		if sample.RSSBytes == 0 || sample.Threads < sample.Processes {
+			t.Errorf("implausible sample %+v", sample)
+		}
+	}
+	if peak < 2 {
+		t.Errorf("samples saw at most %d processes, want the shell and its sleep", peak)
+	}
+	if usage == nil || usage.Samples != len(samples) || usage.PeakProcesses != peak || usage.CPUTimeMs == 0 {
+		t.Errorf("usage = %+v, want a summary of the %d samples with CPU time", usage, len(samples))
+	}
+}
+
+// Children spawned without a stats interval are not sampled.
+func TestProcessStatsAreOptIn(t *testing.T) {
+	svc, mu, captured := recordingProcessService()
+	if _, err := svc.Spawn(processSpawnArgs{Payload: SpawnPayload{Launcher: "sleep", Args: []string{"0.4"}}, Channel: 62}); err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	for _, e := range waitForProcessClose(t, mu, captured, "stream://62") {
+		if e.Type == "stats" || e.Usage != nil {
+			t.Errorf("unsampled child produced %+v", e)
+		}
+	}
+}
+
+func TestReadProcStat(t *testing.T) {
+	stat, err := readProcStat(os.Getpid())
+	if err != nil {
+		t.Fatalf("read own stat: %v", err)
+	}
+	if stat.ppid != os.Getppid() || stat.threads < 1 || stat.rss == 0 {
+		t.Errorf("own stat = %+v, want ppid %d and live threads/rss", stat, os.Getppid())
+	}
+}
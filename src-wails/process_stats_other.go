@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// Resource sampling reads /proc, so it is Linux-only; elsewhere a requested statsIntervalMs is ignored.
+const processStatsSupported = false
+
+func sampleProcessTree(_ int) (ProcessStats, error) {
+	return ProcessStats{}, errors.New("process sampling is supported on Linux only")
+}
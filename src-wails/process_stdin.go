@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// Stdin streaming for pipe-mode children spawned with payload.stdin: `podman load` fed from a file the renderer
+// reads, answers to prompts, a compose file streamed into `kubectl apply -f -`. WriteStdin blocks until the
+// chunk is in the pipe, so a producer that awaits each call is paced by how fast the child reads (the pipe buffer
+// is the only queue). Concurrent writes to one child are serialized, whole chunk by whole chunk. CloseStdin does not
+// queue behind them: closing the pipe fails a write still blocked on a child that stopped reading.
+
+type processStdinArgs struct {
+	Payload ProcessStdinPayload `json:"payload"`
+}
+
+// ProcessStdinPayload names the child whose stdin CloseStdin ends.
+type ProcessStdinPayload struct {
+	ProcessID string `json:"processId"`
+}
+
+// childStdin is the write end of a child's stdin pipe; mu serializes writers, closed is set by CloseStdin.
+type childStdin struct {
+	mu     sync.Mutex
+	pipe   io.WriteCloser
+	closed atomic.Bool
+}
+
+// WriteStdin writes a chunk to the child's stdin (a PTY-mode child's terminal, like Write) and returns once the
+// child's pipe has taken all of it.
+func (s *ProcessService) WriteStdin(args processWriteArgs) error {
+	child, err := s.running(args.Payload.ProcessID)
+	if err != nil {
+		return err
+	}
+	data, err := args.Payload.bytes()
+	if err != nil {
+		return err
+	}
+	if child.pty != nil {
+		_, err = child.pty.Write(data)
+		return stdinError(args.Payload.ProcessID, err)
+	}
+	if child.stdin == nil {
+		return errors.New("process " + args.Payload.ProcessID + " was not spawned with stdin")
+	}
+	child.stdin.mu.Lock()
+	defer child.stdin.mu.Unlock()
+	if child.stdin.closed.Load() {
+		return errors.New("stdin of process " + args.Payload.ProcessID + " is closed")
+	}
+	if _, err = child.stdin.pipe.Write(data); err != nil && child.stdin.closed.Load() {
+		return errors.New("stdin of process " + args.Payload.ProcessID + " was closed during the write")
+	}
+	return stdinError(args.Payload.ProcessID, err)
+}
+
+// CloseStdin ends the child's stdin (EOF for `kubectl apply -f -` and friends), failing a write still in progress.
+// Closing twice is a no-op.
+func (s *ProcessService) CloseStdin(args processStdinArgs) error {
+	child, err := s.running(args.Payload.ProcessID)
+	if err != nil {
+		return err
+	}
+	if child.stdin == nil {
+		return errors.New("process " + args.Payload.ProcessID + " was not spawned with stdin")
+	}
+	if child.stdin.closed.Swap(true) {
+		return nil
+	}
+	if err := child.stdin.pipe.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *ProcessService) running(processID string) (*spawnedProcess, error) {
+	s.mu.Lock()
+	child, ok := s.children[processID]
+	s.mu.Unlock()
+	if !ok {
+		return nil, errors.New("process " + processID + " has exited or does not exist")
+	}
+	return child, nil
+}
+
+func (p ProcessWritePayload) bytes() ([]byte, error) {
+	if p.Binary {
+		return base64.StdEncoding.DecodeString(p.Data)
+	}
+	return []byte(p.Data), nil
+}
+
+// stdinError turns the errors of writing to a child that is gone (its end of the pipe closed: EPIPE; or ours,
+// closed by cmd.Wait: ErrClosed) into one that says so.
+func stdinError(processID string, err error) error {
+	if errors.Is(err, syscall.EPIPE) || errors.Is(err, os.ErrClosed) {
+		return errors.New("process " + processID + " has exited")
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+//go:build !windows
+
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Chunks written to a child's stdin reach it in order, CloseStdin delivers EOF, and writing once it has exited
+// says so.
+func TestProcessStdinStreaming(t *testing.T) {
+	var mu sync.Mutex
+	captured := map[string][]processEvent{}
+	svc := &ProcessService{emit: func(name string, data any) {
+		mu.Lock()
+		defer mu.Unlock()
+		captured[name] = append(captured[name], data.(processEvent))
+	}}
+	res, err := svc.Spawn(processSpawnArgs{Payload: SpawnPayload{Launcher: "cat", Stdin: true}, Channel: 11})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	if err := svc.WriteStdin(processWriteArgs{Payload: ProcessWritePayload{ProcessID: res.ProcessID, Data: "kind: "}}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	// "Pod\n" as base64.
+	if err := svc.WriteStdin(processWriteArgs{Payload: ProcessWritePayload{ProcessID: res.ProcessID, Data: "UG9kCg==", Binary: true}}); err != nil {
+		t.Fatalf("binary write: %v", err)
+	}
+	if err := svc.CloseStdin(processStdinArgs{Payload: ProcessStdinPayload{ProcessID: res.ProcessID}}); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := svc.WriteStdin(processWriteArgs{Payload: ProcessWritePayload{ProcessID: res.ProcessID, Data: "late"}}); err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Errorf("write after close error = %v, want stdin closed", err)
+	}
+
+	events := waitForProcessClose(t, &mu, captured, "stream://11")
+	var stdout string
+	for _, e := range events {
+		if e.Type == "data" && e.From == "stdout" {
+			stdout += e.Data
+		}
+	}
+	if stdout != "kind: Pod\n" {
+		t.Errorf("stdout = %q, want the chunks in order", stdout)
+	}
+	if err := svc.WriteStdin(processWriteArgs{Payload: ProcessWritePayload{ProcessID: res.ProcessID, Data: "x"}}); err == nil || !strings.Contains(err.Error(), "exited") {
+		t.Errorf("write after exit error = %v, want an exited error", err)
+	}
+}
+
+// A write bigger than the pipe buffer returns only once the child has read it, and a child that exits without
+// reading fails the write with an exited error instead of hanging it.
+func TestProcessStdinBackpressure(t *testing.T) {
+	svc := &ProcessService{emit: func(string, any) {}}
+	chunk := strings.Repeat("x", 1<<20)
+
+	res, err := svc.Spawn(processSpawnArgs{Payload: SpawnPayload{Launcher: "sh", Args: []string{"-c", "sleep 0.3; exec cat >/dev/null"}, Stdin: true}, Channel: 12})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	started := time.Now()
+	if err := svc.WriteStdin(processWriteArgs{Payload: ProcessWritePayload{ProcessID: res.ProcessID, Data: chunk}}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed < 250*time.Millisecond {
+		t.Errorf("1 MiB write returned after %v, before the child started reading", elapsed)
+	}
+	_ = svc.CloseStdin(processStdinArgs{Payload: ProcessStdinPayload{ProcessID: res.ProcessID}})
+
+	res, err = svc.Spawn(processSpawnArgs{Payload: SpawnPayload{Launcher: "sh", Args: []string{"-c", "exit 0"}, Stdin: true}, Channel: 13})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- svc.WriteStdin(processWriteArgs{Payload: ProcessWritePayload{ProcessID: res.ProcessID, Data: chunk}})
+	}()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "exited") {
+			t.Errorf("write to an exiting child error = %v, want an exited error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("write to an exited child hung")
+	}
+}
+
+// CloseStdin does not wait behind a write blocked on a child that never reads: it closes the pipe, which fails
+// that write.
+func TestProcessCloseStdinDuringBlockedWrite(t *testing.T) {
+	svc := &ProcessService{emit: func(string, any) {}}
+	res, err := svc.Spawn(processSpawnArgs{Payload: SpawnPayload{Launcher: "sleep", Args: []string{"10"}, Stdin: true}, Channel: 15})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	defer svc.Kill(processKillArgs{Payload: KillPayload{ProcessID: res.ProcessID, Signal: "SIGKILL"}})
+	written := make(chan error, 1)
+	go func() {
+		written <- svc.WriteStdin(processWriteArgs{Payload: ProcessWritePayload{ProcessID: res.ProcessID, Data: strings.Repeat("x", 1<<20)}})
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- svc.CloseStdin(processStdinArgs{Payload: ProcessStdinPayload{ProcessID: res.ProcessID}})
+	}()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("close: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("CloseStdin waited behind a blocked write")
+	}
+	select {
+	case err := <-written:
+		if err == nil || !strings.Contains(err.Error(), "closed") {
+			t.Errorf("blocked write error = %v, want stdin closed", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the blocked write was not released by CloseStdin")
+	}
+}
+
+// A child spawned without payload.stdin has none to write to.
+func TestProcessStdinRequiresOptIn(t *testing.T) {
+	svc := &ProcessService{emit: func(string, any) {}}
+	res, err := svc.Spawn(processSpawnArgs{Payload: SpawnPayload{Launcher: "sleep", Args: []string{"10"}}, Channel: 14})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	defer svc.Kill(processKillArgs{Payload: KillPayload{ProcessID: res.ProcessID, Signal: "SIGKILL"}})
+	if err := svc.WriteStdin(processWriteArgs{Payload: ProcessWritePayload{ProcessID: res.ProcessID, Data: "x"}}); err == nil || !strings.Contains(err.Error(), "stdin") {
+		t.Errorf("write error = %v, want a no-stdin error", err)
+	}
+	if err := svc.CloseStdin(processStdinArgs{Payload: ProcessStdinPayload{ProcessID: "proc-does-not-exist"}}); err == nil {
+		t.Error("closing stdin of an unknown process succeeded")
+	}
+}
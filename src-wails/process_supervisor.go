@@ -0,0 +1,511 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supervised background services — `podman system service`, relay helpers — which Spawn would start once and then
+// forget. Supervise runs the command under a restart policy:
+//   - "never": the default.
+//   - "on-failure": restart after a non-zero exit, a signal, a failed readiness wait or an unhealthy kill.
+//   - "always": restart after any exit.
+// Restarts back off exponentially. A run that stays up for supervisorStableRun resets both the backoff and the
+// retry count. Readiness is a log pattern matched on stdout/stderr or a socket that accepts a dial; with neither,
+// a started child is ready at once. Once ready, an optional health command runs on an interval, and enough failures
+// in a row kill the child (and so restart it). Each run is an ordinary registered child streaming to the service's
+// channel, so Kill, List and Attach work on it. The service's own transitions arrive there as "state" events.
+
+const (
+	supervisorDefaultBackoff    = time.Second
+	supervisorDefaultMaxBackoff = 30 * time.Second
+	supervisorStableRun         = time.Minute
+	supervisorDefaultStopGrace  = 5 * time.Second
+	healthDefaultInterval       = 10 * time.Second
+	healthDefaultTimeout        = 5 * time.Second
+	healthDefaultRetries        = 3
+	readinessPollInterval       = 100 * time.Millisecond
+	// readinessTailBytes is how much of a partial last line is kept so a pattern split across chunks still matches.
+	readinessTailBytes = 4096
+	// healthOutputBytes is how much of a failing health check's output (its tail) is kept for the reason.
+	healthOutputBytes = 4096
+)
+
+// RestartPolicy is when a supervised service is restarted. MaxRetries caps consecutive restarts (0 = no cap);
+// the backoff starts at BackoffMs and doubles up to MaxBackoffMs.
+type RestartPolicy struct {
+	Mode         string `json:"mode"`
+	MaxRetries   int    `json:"maxRetries,omitempty"`
+	BackoffMs    int    `json:"backoffMs,omitempty"`
+	MaxBackoffMs int    `json:"maxBackoffMs,omitempty"`
+}
+
+// HealthCheck is a command that must exit 0 within TimeoutMs, run every IntervalMs once the service is ready;
+// Retries consecutive failures mark the service unhealthy. It runs in the service's Cwd and environment.
+type HealthCheck struct {
+	Launcher   string   `json:"launcher"`
+	Args       []string `json:"args"`
+	IntervalMs int      `json:"intervalMs,omitempty"`
+	TimeoutMs  int      `json:"timeoutMs,omitempty"`
+	Retries    int      `json:"retries,omitempty"`
+}
+
+// Readiness is when a started service counts as ready: LogPattern (a regexp) matching its output, or Socket (a
+// unix:// | npipe:// URI or path) accepting a connection — whichever comes first. TimeoutMs > 0 fails a run that
+// is not ready in time.
+type Readiness struct {
+	LogPattern string `json:"logPattern,omitempty"`
+	Socket     string `json:"socket,omitempty"`
+	TimeoutMs  int    `json:"timeoutMs,omitempty"`
+}
+
+// SupervisePayload is the command (as for Spawn; a PTY is not supported) plus its supervision.
+type SupervisePayload struct {
+	SpawnPayload
+	Restart     RestartPolicy `json:"restart"`
+	Health      *HealthCheck  `json:"health,omitempty"`
+	Readiness   *Readiness    `json:"readiness,omitempty"`
+	StopGraceMs int           `json:"stopGraceMs,omitempty"`
+}
+
+type superviseArgs struct {
+	Payload SupervisePayload `json:"payload"`
+	Channel uint64           `json:"channel"`
+}
+
+type serviceStopArgs struct {
+	Payload ServiceStopPayload `json:"payload"`
+}
+
+// ServiceStopPayload names the service to stop; its current run gets SIGTERM, then SIGKILL after GraceMs
+// (default: the service's StopGraceMs, else 5s).
+type ServiceStopPayload struct {
+	ServiceID string `json:"serviceId"`
+	GraceMs   int    `json:"graceMs,omitempty"`
+}
+
+// ServiceInfo is one supervised service. State is starting | running | ready | unhealthy | backoff, or, once
+// supervision has ended, exited | failed | stopped. ProcessID/Pid are the current (or last) run's.
+type ServiceInfo struct {
+	ServiceID    string   `json:"serviceId"`
+	Launcher     string   `json:"launcher"`
+	Args         []string `json:"args"`
+	State        string   `json:"state"`
+	Reason       string   `json:"reason,omitempty"`
+	Restarts     int      `json:"restarts"`
+	ProcessID    string   `json:"processId,omitempty"`
+	Pid          int      `json:"pid,omitempty"`
+	LastExitCode *int     `json:"lastExitCode,omitempty"`
+	StartedAt    string   `json:"startedAt"`
+}
+
+type supervisor struct {
+	service    *ProcessService
+	seq        uint64
+	payload    SupervisePayload
+	channel    uint64
+	logPattern *regexp.Regexp
+	stop       chan struct{}
+	stopOnce   sync.Once
+	stopGrace  time.Duration
+	done       chan struct{}
+
+	mu    sync.Mutex
+	info  ServiceInfo
+	child *spawnedProcess
+}
+
+// runOutcome is how one run of a service ended.
+type runOutcome struct {
+	failed bool
+	reason string
+	ranFor time.Duration
+}
+
+// Supervise starts a supervised service and returns at once; its runs and state changes stream to channel.
+func (s *ProcessService) Supervise(args superviseArgs) (ServiceInfo, error) {
+	payload := args.Payload
+	if payload.Pty {
+		return ServiceInfo{}, errors.New("supervised services run without a pty")
+	}
+	switch payload.Restart.Mode {
+	case "", "never", "on-failure", "always":
+	default:
+		return ServiceInfo{}, fmt.Errorf("unknown restart policy %q", payload.Restart.Mode)
+	}
+	// Authorized once here: restarts and health probes rerun what the user's policy already let through.
+	if err := launchPolicy.authorize(commandRequest{"supervise", payload.Launcher, payload.Args, payload.Cwd, ""}); err != nil {
+		return ServiceInfo{}, err
+	}
+	if check := payload.Health; check != nil {
+		if check.Launcher == "" {
+			return ServiceInfo{}, errors.New("health check launcher is empty")
+		}
+		if err := launchPolicy.authorize(commandRequest{"health", check.Launcher, check.Args, payload.Cwd, ""}); err != nil {
+			return ServiceInfo{}, err
+		}
+	}
+	v := &supervisor{
+		service:   s,
+		payload:   payload,
+		channel:   args.Channel,
+		stop:      make(chan struct{}),
+		stopGrace: supervisorDefaultStopGrace,
+		done:      make(chan struct{}),
+	}
+	if payload.Readiness != nil && payload.Readiness.LogPattern != "" {
+		pattern, err := regexp.Compile(payload.Readiness.LogPattern)
+		if err != nil {
+			return ServiceInfo{}, fmt.Errorf("readiness log pattern: %w", err)
+		}
+		v.logPattern = pattern
+	}
+	if payload.StopGraceMs > 0 {
+		v.stopGrace = time.Duration(payload.StopGraceMs) * time.Millisecond
+	}
+	v.seq = s.counter.Add(1)
+	v.info = ServiceInfo{
+		ServiceID: fmt.Sprintf("svc-%d", v.seq),
+		Launcher:  payload.Launcher,
+		Args:      redactArgs(payload.Args),
+		State:     "starting",
+		StartedAt: time.Now().Format(time.RFC3339),
+	}
+	s.mu.Lock()
+	if s.services == nil {
+		s.services = map[string]*supervisor{}
+	}
+	s.services[v.info.ServiceID] = v
+	s.mu.Unlock()
+	go v.run()
+	return v.snapshot(), nil
+}
+
+// StopService ends supervision: the current run is terminated and not restarted. It returns once the run has
+// exited, and forgets the service (also one whose supervision had already ended).
+func (s *ProcessService) StopService(args serviceStopArgs) error {
+	s.mu.Lock()
+	v, ok := s.services[args.Payload.ServiceID]
+	delete(s.services, args.Payload.ServiceID)
+	s.mu.Unlock()
+	if !ok {
+		return errors.New("no supervised service " + args.Payload.ServiceID)
+	}
+	v.stopOnce.Do(func() {
+		if args.Payload.GraceMs > 0 {
+			v.mu.Lock()
+			v.stopGrace = time.Duration(args.Payload.GraceMs) * time.Millisecond
+			v.mu.Unlock()
+		}
+		close(v.stop)
+	})
+	<-v.done
+	return nil
+}
+
+// ListServices reports the supervised services, oldest first.
+func (s *ProcessService) ListServices() []ServiceInfo {
+	s.mu.Lock()
+	services := make([]*supervisor, 0, len(s.services))
+	for _, v := range s.services {
+		services = append(services, v)
+	}
+	s.mu.Unlock()
+	sort.Slice(services, func(i, j int) bool { return services[i].seq < services[j].seq })
+	out := make([]ServiceInfo, 0, len(services))
+	for _, v := range services {
+		out = append(out, v.snapshot())
+	}
+	return out
+}
+
+func (v *supervisor) run() {
+	defer close(v.done)
+	policy := v.payload.Restart
+	initial, ceiling := supervisorDefaultBackoff, supervisorDefaultMaxBackoff
+	if policy.BackoffMs > 0 {
+		initial = time.Duration(policy.BackoffMs) * time.Millisecond
+	}
+	if policy.MaxBackoffMs > 0 {
+		ceiling = time.Duration(policy.MaxBackoffMs) * time.Millisecond
+	}
+	backoff := initial
+	for {
+		outcome := v.runOnce()
+		if v.stopping() {
+			v.setState("stopped", "")
+			return
+		}
+		if outcome.ranFor >= supervisorStableRun {
+			backoff = initial
+			v.mu.Lock()
+			v.info.Restarts = 0
+			v.mu.Unlock()
+		}
+		restart := policy.Mode == "always" || (policy.Mode == "on-failure" && outcome.failed)
+		if !restart {
+			if outcome.failed {
+				v.setState("failed", outcome.reason)
+			} else {
+				v.setState("exited", outcome.reason)
+			}
+			return
+		}
+		v.mu.Lock()
+		restarts := v.info.Restarts
+		v.mu.Unlock()
+		if policy.MaxRetries > 0 && restarts >= policy.MaxRetries {
+			v.setState("failed", fmt.Sprintf("%s; gave up after %d restarts", outcome.reason, restarts))
+			return
+		}
+		v.setState("backoff", fmt.Sprintf("%s; restarting in %s", outcome.reason, backoff))
+		select {
+		case <-time.After(backoff):
+		case <-v.stop:
+			v.setState("stopped", "")
+			return
+		}
+		backoff = min(backoff*2, ceiling)
+		v.mu.Lock()
+		v.info.Restarts++
+		v.mu.Unlock()
+	}
+}
+
+// runOnce starts the command and follows it until it has exited: readiness, then health checks, terminating it
+// when it is not ready in time, turns unhealthy, or the service is stopped.
+func (v *supervisor) runOnce() runOutcome {
+	v.setState("starting", "")
+	ready := make(chan struct{})
+	var readyOnce sync.Once
+	markReady := func() { readyOnce.Do(func() { close(ready) }) }
+	closed := make(chan processEvent, 1)
+	var exit processEvent
+	var tail string
+	// observe runs under the child's eventsMu, one event at a time.
+	observe := func(event processEvent) {
+		switch event.Type {
+		case "data":
+			if v.logPattern == nil {
+				return
+			}
+			text := tail + event.Data
+			if v.logPattern.MatchString(text) {
+				markReady()
+			}
+			tail = text[strings.LastIndexByte(text, '\n')+1:]
+			if len(tail) > readinessTailBytes {
+				tail = tail[len(tail)-readinessTailBytes:]
+			}
+		case "exit":
+			exit = event
+		case "close":
+			closed <- exit
+		}
+	}
+	child, err := v.service.start(v.payload.SpawnPayload, v.channel, observe)
+	if err != nil {
+		return runOutcome{failed: true, reason: err.Error()}
+	}
+	started := time.Now()
+	v.mu.Lock()
+	v.child = child
+	v.info.ProcessID = child.id
+	v.info.Pid = child.process.Pid
+	v.mu.Unlock()
+	v.setState("running", "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	readiness := v.payload.Readiness
+	if readiness == nil || (readiness.LogPattern == "" && readiness.Socket == "") {
+		markReady()
+	} else if readiness.Socket != "" {
+		go awaitSocket(ctx, flatpakRemap(stripSocketScheme(readiness.Socket)), markReady)
+	}
+	var readyTimeout <-chan time.Time
+	if readiness != nil && readiness.TimeoutMs > 0 {
+		readyTimeout = time.After(durationOr(readiness.TimeoutMs, 0))
+	}
+
+	var healthTicks <-chan time.Time
+	healthFailures := 0
+	// A check runs off the loop so a slow one cannot hold up a stop; its result comes back on healthResults
+	// (buffered, so a check still running when the child exits does not leak), and ticks are skipped meanwhile.
+	healthResults := make(chan error, 1)
+	healthRunning := false
+	forced := ""
+	stop := v.stop
+	terminate := func(reason string) {
+		if forced == "" {
+			forced = reason
+		}
+		v.mu.Lock()
+		grace := v.stopGrace
+		v.mu.Unlock()
+		child.terminate(15, grace)
+	}
+	for {
+		select {
+		case event := <-closed:
+			outcome := runOutcome{reason: forced, ranFor: time.Since(started)}
+			v.mu.Lock()
+			v.info.LastExitCode = event.Code
+			v.mu.Unlock()
+			switch {
+			case forced != "":
+				outcome.failed = true
+			case event.Signal != "":
+				outcome.failed, outcome.reason = true, "killed by "+event.Signal
+			case event.Code == nil || *event.Code != 0:
+				outcome.failed, outcome.reason = true, "exited with code "+formatExitCode(event.Code)
+			default:
+				outcome.reason = "exited with code 0"
+			}
+			return outcome
+		case <-ready:
+			ready, readyTimeout = nil, nil
+			v.setState("ready", "")
+			if health := v.payload.Health; health != nil {
+				ticker := time.NewTicker(durationOr(health.IntervalMs, healthDefaultInterval))
+				defer ticker.Stop()
+				healthTicks = ticker.C
+			}
+		case <-readyTimeout:
+			readyTimeout, ready = nil, nil
+			terminate("not ready within " + durationOr(readiness.TimeoutMs, 0).String())
+		case <-healthTicks:
+			if healthRunning {
+				continue
+			}
+			healthRunning = true
+			go func() { healthResults <- runHealthCheck(v.payload.Health, v.payload.SpawnPayload) }()
+		case err := <-healthResults:
+			healthRunning = false
+			if healthTicks == nil {
+				continue
+			}
+			if err == nil {
+				healthFailures = 0
+				continue
+			}
+			healthFailures++
+			retries := v.payload.Health.Retries
+			if retries <= 0 {
+				retries = healthDefaultRetries
+			}
+			if healthFailures >= retries {
+				healthTicks = nil
+				reason := fmt.Sprintf("health check failed %d times: %v", healthFailures, err)
+				v.setState("unhealthy", reason)
+				terminate(reason)
+			}
+		case <-stop:
+			stop = nil
+			terminate("stopped")
+		}
+	}
+}
+
+func (v *supervisor) stopping() bool {
+	select {
+	case <-v.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// setState records a transition and emits it as a "state" event on the service's channel.
+func (v *supervisor) setState(state, reason string) {
+	v.mu.Lock()
+	v.info.State = state
+	v.info.Reason = reason
+	info := v.info
+	v.mu.Unlock()
+	v.service.emitProcess(streamEventName(v.channel), processEvent{
+		ProcessID: info.ProcessID,
+		Type:      "state",
+		ServiceID: info.ServiceID,
+		State:     state,
+		Reason:    reason,
+		Restarts:  info.Restarts,
+	})
+}
+
+func (v *supervisor) snapshot() ServiceInfo {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.info
+}
+
+// awaitSocket dials the socket until it accepts a connection (ready) or ctx ends.
+func awaitSocket(ctx context.Context, socket string, ready func()) {
+	for {
+		dialCtx, cancel := context.WithTimeout(ctx, time.Second)
+		conn, err := dialLocalTransport(dialCtx, socket)
+		cancel()
+		if err == nil {
+			_ = conn.Close()
+			ready()
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(readinessPollInterval):
+		}
+	}
+}
+
+// runHealthCheck runs the health command once, in the service's cwd and environment; a non-zero exit, a timeout
+// or a failed start is unhealthy. Like Execute, a timeout takes down its whole process group, and output held
+// open by a grandchild is given up on after execWaitDelay. Only the last healthOutputBytes of output are kept.
+func runHealthCheck(check *HealthCheck, service SpawnPayload) error {
+	ctx, cancel := context.WithTimeout(context.Background(), durationOr(check.TimeoutMs, healthDefaultTimeout))
+	defer cancel()
+	cmd := exec.CommandContext(ctx, check.Launcher, check.Args...)
+	cmd.Dir = service.Cwd
+	cmd.Env = spawnEnv(service)
+	configureHiddenWindow(cmd)
+	newProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		deliverSignal(cmd.Process, 9)
+		return nil
+	}
+	cmd.WaitDelay = execWaitDelay
+	output := newRingBuffer(healthOutputBytes)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return errors.New("timed out")
+		}
+		if text := strings.TrimSpace(string(output.Bytes())); text != "" {
+			return fmt.Errorf("%w: %s", err, text)
+		}
+		return err
+	}
+	return nil
+}
+
+func durationOr(ms int, fallback time.Duration) time.Duration {
+	if ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return fallback
+}
+
+func formatExitCode(code *int) string {
+	if code == nil {
+		return "unknown"
+	}
+	return fmt.Sprint(*code)
+}
@@ -0,0 +1,227 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// on-failure restarts a crashing service with backoff until MaxRetries, then reports it failed.
+func TestSuperviseRestartsOnFailureUpToMaxRetries(t *testing.T) {
+	svc, mu, captured := recordingProcessService()
+	info, err := svc.Supervise(superviseArgs{Payload: SupervisePayload{
+		SpawnPayload: SpawnPayload{Launcher: "sh", Args: []string{"-c", "exit 3"}},
+		Restart:      RestartPolicy{Mode: "on-failure", MaxRetries: 2, BackoffMs: 10},
+	}, Channel: 41})
+	if err != nil {
+		t.Fatalf("supervise: %v", err)
+	}
+	final := waitForServiceState(t, mu, captured, "stream://41", "failed")
+	if final.Restarts != 2 || !strings.Contains(final.Reason, "exited with code 3") {
+		t.Errorf("final state = %+v, want failed after 2 restarts with the exit code", final)
+	}
+	if runs := len(serviceStates(mu, captured, "stream://41", "running")); runs != 3 {
+		t.Errorf("service ran %d times, want 3", runs)
+	}
+	if list := svc.ListServices(); len(list) != 1 || list[0].ServiceID != info.ServiceID || list[0].State != "failed" {
+		t.Errorf("services = %+v, want the failed one still listed", list)
+	}
+	if err := svc.StopService(serviceStopArgs{Payload: ServiceStopPayload{ServiceID: info.ServiceID}}); err != nil {
+		t.Errorf("stop after failure: %v", err)
+	}
+	if list := svc.ListServices(); len(list) != 0 {
+		t.Errorf("services after stop = %+v, want none", list)
+	}
+}
+
+// A log pattern, even split across output chunks, makes the service ready; StopService ends it for good.
+func TestSuperviseReadyByLogPatternThenStop(t *testing.T) {
+	svc, mu, captured := recordingProcessService()
+	info, err := svc.Supervise(superviseArgs{Payload: SupervisePayload{
+		SpawnPayload: SpawnPayload{Launcher: "sh", Args: []string{"-c", "echo booting; printf 'listening on '; sleep 0.1; echo 1234; exec sleep 30"}},
+		Restart:      RestartPolicy{Mode: "always"},
+		Readiness:    &Readiness{LogPattern: `listening on \d+`},
+	}, Channel: 42})
+	if err != nil {
+		t.Fatalf("supervise: %v", err)
+	}
+	waitForServiceState(t, mu, captured, "stream://42", "ready")
+	if err := svc.StopService(serviceStopArgs{Payload: ServiceStopPayload{ServiceID: info.ServiceID, GraceMs: 1000}}); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	waitForServiceState(t, mu, captured, "stream://42", "stopped")
+	if runs := len(serviceStates(mu, captured, "stream://42", "running")); runs != 1 {
+		t.Errorf("an always service ran %d times before a stop, want 1 (no restart after stopping)", runs)
+	}
+	if processes := svc.List(); len(processes) != 0 {
+		t.Errorf("processes after stop = %+v, want none", processes)
+	}
+}
+
+// A socket that starts accepting connections makes the service ready.
+func TestSuperviseReadyBySocket(t *testing.T) {
+	svc, mu, captured := recordingProcessService()
+	socket := filepath.Join(t.TempDir(), "engine.sock")
+	info, err := svc.Supervise(superviseArgs{Payload: SupervisePayload{
+		SpawnPayload: SpawnPayload{Launcher: "sleep", Args: []string{"30"}},
+		Readiness:    &Readiness{Socket: "unix://" + socket},
+	}, Channel: 43})
+	if err != nil {
+		t.Fatalf("supervise: %v", err)
+	}
+	defer func() { _ = svc.StopService(serviceStopArgs{Payload: ServiceStopPayload{ServiceID: info.ServiceID}}) }()
+	time.Sleep(200 * time.Millisecond)
+	if states := serviceStates(mu, captured, "stream://43", "ready"); len(states) != 0 {
+		t.Fatal("ready before the socket existed")
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+	waitForServiceState(t, mu, captured, "stream://43", "ready")
+}
+
+// Consecutive health check failures kill the service; with restart "never" it then ends as failed.
+func TestSuperviseUnhealthyKillsService(t *testing.T) {
+	svc, mu, captured := recordingProcessService()
+	if _, err := svc.Supervise(superviseArgs{Payload: SupervisePayload{
+		SpawnPayload: SpawnPayload{Launcher: "sleep", Args: []string{"30"}},
+		Health:       &HealthCheck{Launcher: "sh", Args: []string{"-c", "echo refused >&2; exit 1"}, IntervalMs: 20, Retries: 2},
+	}, Channel: 44}); err != nil {
+		t.Fatalf("supervise: %v", err)
+	}
+	unhealthy := waitForServiceState(t, mu, captured, "stream://44", "unhealthy")
+	if !strings.Contains(unhealthy.Reason, "failed 2 times") || !strings.Contains(unhealthy.Reason, "refused") {
+		t.Errorf("unhealthy reason = %q", unhealthy.Reason)
+	}
+	final := waitForServiceState(t, mu, captured, "stream://44", "failed")
+	if !strings.Contains(final.Reason, "health check failed") {
+		t.Errorf("failed reason = %q, want the health failure", final.Reason)
+	}
+}
+
+// A health check runs off the supervisor's loop: a stop does not wait for a slow one, and a chatty one's output
+// is capped.
+func TestHealthCheckDoesNotBlockStop(t *testing.T) {
+	svc, mu, captured := recordingProcessService()
+	info, err := svc.Supervise(superviseArgs{Payload: SupervisePayload{
+		SpawnPayload: SpawnPayload{Launcher: "sleep", Args: []string{"30"}},
+		Health:       &HealthCheck{Launcher: "sleep", Args: []string{"30"}, IntervalMs: 10, TimeoutMs: 30000},
+	}, Channel: 46})
+	if err != nil {
+		t.Fatalf("supervise: %v", err)
+	}
+	waitForServiceState(t, mu, captured, "stream://46", "ready")
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	if err := svc.StopService(serviceStopArgs{Payload: ServiceStopPayload{ServiceID: info.ServiceID, GraceMs: 1000}}); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	waitForServiceState(t, mu, captured, "stream://46", "stopped")
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("stop took %v behind a running health check", elapsed)
+	}
+
+	chatty := &HealthCheck{Launcher: "sh", Args: []string{"-c", "head -c 1000000 /dev/zero | tr '\\0' x; exit 1"}}
+	err = runHealthCheck(chatty, SpawnPayload{})
+	if err == nil || len(err.Error()) > healthOutputBytes+100 {
+		t.Errorf("chatty health check error is %d bytes, want at most about %d", len(fmt.Sprint(err)), healthOutputBytes)
+	}
+}
+
+// A service that never logs its ready line is terminated once the readiness timeout passes.
+func TestSuperviseReadinessTimeout(t *testing.T) {
+	svc, mu, captured := recordingProcessService()
+	if _, err := svc.Supervise(superviseArgs{Payload: SupervisePayload{
+		SpawnPayload: SpawnPayload{Launcher: "sleep", Args: []string{"30"}},
+		Readiness:    &Readiness{LogPattern: "never printed", TimeoutMs: 100},
+	}, Channel: 45}); err != nil {
+		t.Fatalf("supervise: %v", err)
+	}
+	final := waitForServiceState(t, mu, captured, "stream://45", "failed")
+	if !strings.Contains(final.Reason, "not ready within 100ms") {
+		t.Errorf("failed reason = %q", final.Reason)
+	}
+	if _, err := svc.Supervise(superviseArgs{Payload: SupervisePayload{Restart: RestartPolicy{Mode: "sometimes"}}}); err == nil {
+		t.Error("an unknown restart policy was accepted")
+	}
+}
+
+func serviceStates(mu *sync.Mutex, captured map[string][]processEvent, event, state string) []processEvent {
+	mu.Lock()
+	defer mu.Unlock()
+	var out []processEvent
+	for _, e := range captured[event] {
+		if e.Type == "state" && e.State == state {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func waitForServiceState(t *testing.T, mu *sync.Mutex, captured map[string][]processEvent, event, state string) processEvent {
+	t.Helper()
+	var found []processEvent
+	waitFor(t, "service state "+state, func() bool {
+		found = serviceStates(mu, captured, event, state)
+		return len(found) > 0
+	})
+	return found[len(found)-1]
+}
+
+// The health check runs in the service's cwd and environment, and a timed-out one takes its process group down
+// instead of waiting on a grandchild that holds its output open.
+func TestHealthCheckRunsLikeTheService(t *testing.T) {
+	dir := t.TempDir()
+	service := SpawnPayload{Cwd: dir, Env: map[string]string{"CD_HEALTH_MARK": "svc"}}
+	check := &HealthCheck{Launcher: "sh", Args: []string{"-c", `[ "$(pwd -P)" = "$1" ] && [ "$CD_HEALTH_MARK" = svc ]`, "sh", resolvedDir(t, dir)}}
+	if err := runHealthCheck(check, service); err != nil {
+		t.Errorf("health check in the service's cwd and env: %v", err)
+	}
+
+	pidFile := filepath.Join(dir, "grandchild.pid")
+	slow := &HealthCheck{Launcher: "sh", Args: []string{"-c", "sleep 30 & echo $! > " + pidFile + "; wait"}, TimeoutMs: 200}
+	started := time.Now()
+	if err := runHealthCheck(slow, service); err == nil || err.Error() != "timed out" {
+		t.Errorf("slow health check error = %v, want timed out", err)
+	}
+	if elapsed := time.Since(started); elapsed > execWaitDelay {
+		t.Errorf("timed-out health check returned after %v", elapsed)
+	}
+	raw, _ := os.ReadFile(pidFile)
+	grandchild, _ := strconv.Atoi(strings.TrimSpace(string(raw)))
+	waitFor(t, "the health check's grandchild to exit", func() bool { return grandchild > 0 && !pidAlive(grandchild) })
+}
+
+// A health check without a launcher is refused up front rather than silently never run.
+func TestSuperviseRejectsEmptyHealthLauncher(t *testing.T) {
+	svc := &ProcessService{emit: func(string, any) {}}
+	_, err := svc.Supervise(superviseArgs{Payload: SupervisePayload{
+		SpawnPayload: SpawnPayload{Launcher: "sleep", Args: []string{"30"}},
+		Health:       &HealthCheck{},
+	}, Channel: 47})
+	if err == nil || !strings.Contains(err.Error(), "health check launcher") {
+		t.Fatalf("supervise error = %v, want the empty health launcher refused", err)
+	}
+	if services := svc.ListServices(); len(services) != 0 {
+		t.Errorf("services = %+v, want none started", services)
+	}
+}
+
+func resolvedDir(t *testing.T, dir string) string {
+	t.Helper()
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resolved
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Spawn deadlines: payload.timeoutMs caps a child's wall-clock run; payload.idleTimeoutMs ends a child that has
+// printed nothing for that long (a hung `podman pull`, an ssh waiting on a prompt nobody sees). Output on any
+// stream counts as activity; input does not. When either fires, a "timeout" event ("wall" | "idle") is published,
+// then the child's group gets SIGTERM and, after processTimeoutGrace, SIGKILL. Its exit event repeats which
+// timeout ended it.
+
+const processTimeoutGrace = 2 * time.Second
+
+// watchTimeouts enforces the child's deadlines until it exits (a no-op without any).
+func (s *ProcessService) watchTimeouts(child *spawnedProcess, wallMs, idleMs uint64) {
+	if wallMs == 0 && idleMs == 0 {
+		return
+	}
+	child.lastOutput.Store(time.Now().UnixNano())
+	go func() {
+		var wall, idle <-chan time.Time
+		if wallMs > 0 {
+			timer := time.NewTimer(time.Duration(wallMs) * time.Millisecond)
+			defer timer.Stop()
+			wall = timer.C
+		}
+		idleLimit := time.Duration(idleMs) * time.Millisecond
+		var idleTimer *time.Timer
+		if idleMs > 0 {
+			idleTimer = time.NewTimer(idleLimit)
+			defer idleTimer.Stop()
+			idle = idleTimer.C
+		}
+		for {
+			select {
+			case <-child.exited:
+				return
+			case <-wall:
+				s.timeOut(child, "wall", fmt.Sprintf("ran longer than %s", time.Duration(wallMs)*time.Millisecond))
+				return
+			case <-idle:
+				quiet := time.Since(time.Unix(0, child.lastOutput.Load()))
+				if quiet < idleLimit {
+					idleTimer.Reset(idleLimit - quiet)
+					continue
+				}
+				s.timeOut(child, "idle", fmt.Sprintf("no output for %s", idleLimit))
+				return
+			}
+		}
+	}()
+}
+
+func (s *ProcessService) timeOut(child *spawnedProcess, kind, reason string) {
+	child.timedOut.Store(kind)
+	s.publish(child, processEvent{Type: "timeout", Timeout: kind, Reason: reason})
+	child.terminate(15, processTimeoutGrace)
+}
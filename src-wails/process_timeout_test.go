@@ -0,0 +1,111 @@
+//go:build !windows
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// The wall-clock timeout ends a long child with a timeout event before its exit.
+func TestSpawnWallTimeout(t *testing.T) {
+	svc, mu, captured := recordingProcessService()
+	if _, err := svc.Spawn(processSpawnArgs{Payload: SpawnPayload{Launcher: "sleep", Args: []string{"30"}, TimeoutMs: 200}, Channel: 81}); err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	events := waitForProcessClose(t, mu, captured, "stream://81")
+	types := []string{}
+	for _, e := range events {
+		types = append(types, e.Type)
+		switch e.Type {
+		case "timeout":
+			if e.Timeout != "wall" || !strings.Contains(e.Reason, "200ms") {
+				t.Errorf("timeout event = %+v, want a wall timeout of 200ms", e)
+			}
+		case "exit":
+			if e.Timeout != "wall" || e.Signal != "SIGTERM" {
+				t.Errorf("exit = %+v, want it ended by the wall timeout's SIGTERM", e)
+			}
+		}
+	}
+	if strings.Join(types, ",") != "timeout,exit,close" {
+		t.Errorf("events = %v, want timeout before exit", types)
+	}
+}
+
+// The idle timeout fires only after the child has gone quiet for the whole period; output keeps it alive.
+func TestSpawnIdleTimeout(t *testing.T) {
+	svc, mu, captured := recordingProcessService()
+	started := time.Now()
+	if _, err := svc.Spawn(processSpawnArgs{Payload: SpawnPayload{
+		Launcher:      "sh",
+		Args:          []string{"-c", "for i in 1 2 3 4 5; do echo $i; sleep 0.1; done; exec sleep 30"},
+		IdleTimeoutMs: 300,
+	}, Channel: 82}); err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	events := waitForProcessClose(t, mu, captured, "stream://82")
+	if elapsed := time.Since(started); elapsed < 700*time.Millisecond {
+		t.Errorf("ended after %v, before 300ms of silence following 0.5s of output", elapsed)
+	}
+	var output string
+	timedOut := false
+	for _, e := range events {
+		if e.Type == "data" {
+			output += e.Data
+		}
+		if e.Type == "timeout" && e.Timeout == "idle" {
+			timedOut = true
+		}
+	}
+	if !timedOut || output != "1\n2\n3\n4\n5\n" {
+		t.Errorf("timed out %v with output %q, want an idle timeout after all the output", timedOut, output)
+	}
+}
+
+// A child that keeps printing, or finishes in time, sees no timeout.
+func TestSpawnWithinTimeouts(t *testing.T) {
+	svc, mu, captured := recordingProcessService()
+	if _, err := svc.Spawn(processSpawnArgs{Payload: SpawnPayload{
+		Launcher:      "sh",
+		Args:          []string{"-c", "for i in 1 2 3 4 5 6; do echo $i; sleep 0.1; done"},
+		TimeoutMs:     5000,
+		IdleTimeoutMs: 400,
+	}, Channel: 83}); err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	for _, e := range waitForProcessClose(t, mu, captured, "stream://83") {
+		if e.Type == "timeout" || e.Timeout != "" || (e.Type == "exit" && (e.Code == nil || *e.Code != 0)) {
+			t.Errorf("unexpected %+v", e)
+		}
+	}
+}
+
+// Isolation starts from an empty environment; the allow-list keeps only the named (or prefixed) variables.
+func TestSpawnEnvIsolation(t *testing.T) {
+	t.Setenv("CD_TEST_KEEP", "kept")
+	t.Setenv("CD_OTHER", "leaked")
+	run := func(payload SpawnPayload, channel uint64) string {
+		svc, mu, captured := recordingProcessService()
+		payload.Launcher, payload.Args = "/usr/bin/env", nil
+		if _, err := svc.Spawn(processSpawnArgs{Payload: payload, Channel: channel}); err != nil {
+			t.Fatalf("spawn: %v", err)
+		}
+		waitForProcessClose(t, mu, captured, streamEventName(channel))
+		return streamedStdout(mu, captured, streamEventName(channel))
+	}
+
+	isolated := run(SpawnPayload{Isolate: true, Env: map[string]string{"EXTRA": "3"}}, 84)
+	if isolated != "EXTRA=3\n" {
+		t.Errorf("isolated env = %q, want only the explicit variable", isolated)
+	}
+	allowed := run(SpawnPayload{Isolate: true, InheritEnv: []string{"PATH", "CD_TEST_*"}}, 85)
+	if !strings.Contains(allowed, "CD_TEST_KEEP=kept\n") || !strings.Contains(allowed, "PATH=") || strings.Contains(allowed, "CD_OTHER") {
+		t.Errorf("allow-listed env = %q", allowed)
+	}
+	inherited := run(SpawnPayload{}, 86)
+	if !strings.Contains(inherited, "CD_OTHER=leaked\n") {
+		t.Error("the default spawn no longer inherits the parent environment")
+	}
+}
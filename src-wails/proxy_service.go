@@ -78,12 +78,17 @@ type proxyConnection struct {
 }
 
 // bridgeSpec mirrors src/platform/wails/exec/proxy-request.ts BridgeSpec — consumed by BridgeService in Phase 2b.
+// SSH is set only for kind "ssh" (the in-process client, which has no launcher/argv); Pool optionally pre-spawns
+// the children of kind "stdio"; ReadyTimeoutMs bounds the wait for a "tunnel" to answer /_ping (0 = 5 s).
 type bridgeSpec struct {
-	Kind         string   `json:"kind"`
-	Key          string   `json:"key"`
-	LocalAddress string   `json:"localAddress"`
-	Launcher     string   `json:"launcher"`
-	Argv         []string `json:"argv"`
+	Kind           string         `json:"kind"`
+	Key            string         `json:"key"`
+	LocalAddress   string         `json:"localAddress"`
+	Launcher       string         `json:"launcher"`
+	Argv           []string       `json:"argv"`
+	SSH            *sshBridgeSpec `json:"ssh,omitempty"`
+	Pool           *stdioPoolSpec `json:"pool,omitempty"`
+	ReadyTimeoutMs int            `json:"readyTimeoutMs,omitempty"`
 }
 
 // Output — matches src-tauri/src/proxy.rs ProxyResponse field-for-field.
@@ -108,11 +113,20 @@ func (s *ProxyService) Request(args proxyRequestArgs) ProxyResponse {
 	}
 	resp, err := s.doBuffered(payload, socket)
 	if err != nil {
-		return proxyErrorResponse(err)
+		return proxyErrorResponse(withBridgeStderr(payload, err))
 	}
 	return resp
 }
 
+// withBridgeStderr appends the bridge children's recent stderr to a transport error on an SSH/WSL remote — the
+// dial-stdio child's "Permission denied (publickey)" explains a bare EOF far better than the EOF itself.
+func withBridgeStderr(payload proxyRequestPayload, err error) error {
+	if payload.Bridge == nil {
+		return err
+	}
+	return bridges.stderrFor(payload.Bridge.Key).withTail(err)
+}
+
 func proxyErrorResponse(err error) ProxyResponse {
 	message := err.Error()
 	return ProxyResponse{Stream: false, OK: false, Headers: map[string]string{}, Message: &message}
@@ -182,7 +196,11 @@ func (s *ProxyService) clientFor(socket string) *http.Client {
 	}
 	transport := &http.Transport{
 		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
-			return dialLocalTransport(ctx, socket)
+			conn, err := dialLocalTransport(ctx, socket)
+			if err != nil {
+				return nil, err
+			}
+			return bridges.meterDial(socket, conn), nil
 		},
 	}
 	client := &http.Client{Transport: transport}
@@ -266,13 +284,18 @@ func resolveSocketPath(connection proxyConnection) (string, error) {
 	if raw == "" {
 		raw = connection.Settings.API.Connection.URI
 	}
-	stripped := strings.ReplaceAll(strings.ReplaceAll(raw, "npipe://", ""), "unix://", "")
+	stripped := stripSocketScheme(raw)
 	if stripped == "" {
 		return "", errors.New("no socket path (connection.settings.api.connection.uri is empty)")
 	}
 	return flatpakRemap(stripped), nil
 }
 
+// stripSocketScheme drops the unix:// | npipe:// scheme, leaving the socket path / `//./pipe/name` to dial.
+func stripSocketScheme(raw string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(raw, "npipe://", ""), "unix://", "")
+}
+
 // flatpakRemap: Linux Flatpak sandbox → host socket remap (mirrors Api.clients.ts). No-op outside Flatpak / off Linux.
 func flatpakRemap(path string) string {
 	if runtime.GOOS != "linux" {
@@ -466,7 +489,7 @@ func (s *ProxyService) RequestStream(args proxyStreamArgs) (ProxyStreamHandle, e
 	case res := <-done:
 		if res.err != nil {
 			cancel()
-			return ProxyStreamHandle{}, res.err
+			return ProxyStreamHandle{}, withBridgeStderr(args.Payload, res.err)
 		}
 		response = res.resp
 	case <-time.After(proxyStreamOpenTimeoutMs * time.Millisecond):
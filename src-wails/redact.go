@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Secret redaction for anything that echoes a command line back (bridge inventory, job history, audit log).
+// Engine CLIs take credentials as flag values (`--password x`, `--creds=user:pw`), env-style assignments
+// (`REGISTRY_TOKEN=…`) and URL userinfo (`ssh://user:pw@host`); each form keeps its shape with the secret
+// replaced, so the line stays readable.
+
+const redactedValue = "***"
+
+var secretFlagPattern = regexp.MustCompile(`(?i)(pass(word|wd|phrase)?|token|secret|creds?|credentials?|api[-_]?key|auth)$`)
+
+// redactArgs returns a copy of args with secrets masked: the value following a secret-named flag (and `-p`, the
+// password of docker/podman/skopeo/helm `login`), the value of --flag=value / NAME=value pairs with a secret-ish
+// name — also NAME=value nested in a flag's value (`--env=DB_PASSWORD=x`) — and the password in URL userinfo.
+func redactArgs(args []string) []string {
+	out := make([]string, len(args))
+	maskNext := false
+	login := false
+	for index, arg := range args {
+		switch {
+		case maskNext:
+			out[index] = redactedValue
+			maskNext = false
+		case login && strings.HasPrefix(arg, "-p") && !strings.HasPrefix(arg, "--"):
+			// -p <pw>, -p=<pw> or -p<pw> (pflag's attached short form).
+			switch {
+			case arg == "-p":
+				out[index], maskNext = arg, true
+			case strings.HasPrefix(arg, "-p="):
+				out[index] = "-p=" + redactedValue
+			default:
+				out[index] = "-p" + redactedValue
+			}
+		case strings.HasPrefix(arg, "-") && !strings.Contains(arg, "="):
+			out[index] = arg
+			maskNext = isSecretName(arg) && !strings.HasSuffix(strings.ToLower(arg), "-stdin")
+		default:
+			out[index] = redactArg(arg)
+			login = login || arg == "login"
+		}
+	}
+	return out
+}
+
+// redactArg masks a single NAME=value / --flag=value / URL argument (anything else passes through). A flag's
+// value is redacted in turn, so a nested NAME=value or URL is caught too.
+func redactArg(arg string) string {
+	if name, value, ok := strings.Cut(arg, "="); ok {
+		if isSecretName(name) {
+			return name + "=" + redactedValue
+		}
+		if strings.HasPrefix(name, "-") {
+			return name + "=" + redactArg(value)
+		}
+	}
+	if strings.Contains(arg, "://") && strings.Contains(arg, "@") {
+		if parsed, err := url.Parse(arg); err == nil && parsed.User != nil {
+			if _, hasPassword := parsed.User.Password(); hasPassword {
+				parsed.User = url.UserPassword(parsed.User.Username(), redactedValue)
+				return strings.Replace(parsed.String(), url.QueryEscape(redactedValue), redactedValue, 1)
+			}
+		}
+	}
+	return arg
+}
+
+func isSecretName(name string) bool {
+	return secretFlagPattern.MatchString(strings.TrimLeft(name, "-"))
+}
@@ -0,0 +1,60 @@
+package main
+
+import "sync"
+
+// ringBuffer keeps the LAST size bytes written to it — older bytes are dropped, and Dropped counts them — so a
+// chatty child can never grow memory without bound. Safe for concurrent writers (exec.Cmd copies each non-file
+// stream on its own goroutine). buf fills up to size, then wraps: head is where the oldest byte is, and the next
+// write overwrites from there, so a write costs its own length however much is retained.
+type ringBuffer struct {
+	mu      sync.Mutex
+	size    int
+	buf     []byte
+	head    int
+	dropped uint64
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{size: size}
+}
+
+func (r *ringBuffer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	written := len(p)
+	if len(p) >= r.size {
+		// Only the tail of p survives, and nothing retained before it.
+		r.dropped += uint64(len(r.buf) + len(p) - r.size)
+		r.buf = append(r.buf[:0], p[len(p)-r.size:]...)
+		r.head = 0
+		return written, nil
+	}
+	if room := r.size - len(r.buf); room > 0 {
+		fill := min(room, len(p))
+		r.buf = append(r.buf, p[:fill]...)
+		p = p[fill:]
+	}
+	for len(p) > 0 {
+		n := copy(r.buf[r.head:], p)
+		r.dropped += uint64(n)
+		r.head = (r.head + n) % r.size
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Bytes returns a copy of the retained bytes (oldest first).
+func (r *ringBuffer) Bytes() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]byte, 0, len(r.buf))
+	out = append(out, r.buf[r.head:]...)
+	return append(out, r.buf[:r.head]...)
+}
+
+// Dropped reports how many bytes have been evicted since creation.
+func (r *ringBuffer) Dropped() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// The buffer keeps the last size bytes in order across wrap-arounds and oversized writes, counting the rest.
+func TestRingBufferKeepsTheTail(t *testing.T) {
+	ring := newRingBuffer(8)
+	var all strings.Builder
+	for _, chunk := range []string{"abc", "defgh", "ij", "klmnopq", "r", "0123456789ABCDEF", "st"} {
+		_, _ = ring.Write([]byte(chunk))
+		all.WriteString(chunk)
+		want := all.String()
+		dropped := 0
+		if len(want) > 8 {
+			dropped = len(want) - 8
+			want = want[dropped:]
+		}
+		if got := string(ring.Bytes()); got != want || ring.Dropped() != uint64(dropped) {
+			t.Fatalf("after %q: bytes %q dropped %d, want %q dropped %d", chunk, got, ring.Dropped(), want, dropped)
+		}
+	}
+}
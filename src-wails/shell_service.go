@@ -77,6 +77,9 @@ func (s *ShellService) LaunchTerminal(req terminalLaunchRequest) LaunchResult {
 	if title == "" {
 		title = "Container Desktop"
 	}
+	if err := launchPolicy.authorize(commandRequest{"terminal", req.Payload.Launcher, req.Payload.Args, "", ""}); err != nil {
+		return LaunchResult{Success: false, Stderr: err.Error(), Command: req.Payload.Launcher}
+	}
 	return launchTerminal(req.Payload.Launcher, req.Payload.Args, title)
 }
 
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Socket diagnostics — explains WHY dialLocalTransport fails instead of surfacing a bare "dial unix …: permission
+// denied". Inspects the engine socket the proxy would dial (same scheme strip + Flatpak remap as resolveSocketPath)
+// and returns structured findings, each with a remediation the Settings/connection screens can show verbatim.
+
+const socketDiagnosticDialTimeout = 1500 * time.Millisecond
+
+// SocketDiagnosticsRequest carries the connection URI exactly as stored (unix:///…, npipe:////./pipe/…, or a path).
+type SocketDiagnosticsRequest struct {
+	URI string `json:"uri"`
+}
+
+// SocketFinding is one diagnostic line. Severity is "ok" | "info" | "warning" | "error"; Code is a stable id the
+// renderer may key on (e.g. "permission-denied"); Remediation is empty when there is nothing to do.
+type SocketFinding struct {
+	Code        string `json:"code"`
+	Severity    string `json:"severity"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// SocketDiagnostics is the full report. Owner/Group/Mode/InGroup are filled only where the OS exposes unix
+// ownership (empty on Windows named pipes).
+type SocketDiagnostics struct {
+	URI             string          `json:"uri"`
+	Path            string          `json:"path"`
+	ResolvedPath    string          `json:"resolvedPath"`
+	FlatpakRemapped bool            `json:"flatpakRemapped"`
+	Exists          bool            `json:"exists"`
+	IsSocket        bool            `json:"isSocket"`
+	Owner           string          `json:"owner,omitempty"`
+	Group           string          `json:"group,omitempty"`
+	Mode            string          `json:"mode,omitempty"`
+	InGroup         bool            `json:"inGroup"`
+	Reachable       bool            `json:"reachable"`
+	DialError       string          `json:"dialError,omitempty"`
+	SystemdUnits    []string        `json:"systemdUnits"`
+	Findings        []SocketFinding `json:"findings"`
+}
+
+// DiagnoseSocket inspects the socket behind a connection URI: existence, owner/group/mode, whether the current
+// user can reach it through its group, a short dial attempt, the systemd socket units installed for the engine,
+// and how the Flatpak remap rewrote the path. Infallible — every problem is reported as a finding.
+func (s *PlatformService) DiagnoseSocket(req SocketDiagnosticsRequest) SocketDiagnostics {
+	return diagnoseSocket(req.URI)
+}
+
+func diagnoseSocket(uri string) SocketDiagnostics {
+	path := stripSocketScheme(uri)
+	report := SocketDiagnostics{URI: uri, Path: path, SystemdUnits: []string{}, Findings: []SocketFinding{}}
+	if path == "" {
+		report.add("no-path", "error", "The connection has no socket path.", "Set the engine socket URI in the connection settings.")
+		return report
+	}
+	report.ResolvedPath = flatpakRemap(path)
+	if report.ResolvedPath != path {
+		report.FlatpakRemapped = true
+		report.add("flatpak-remap", "info",
+			fmt.Sprintf("Running inside Flatpak: %s is reached through the host mount %s.", path, report.ResolvedPath),
+			"Grant the sandbox access to the socket directory, e.g. flatpak override --user --filesystem="+filepath.Dir(path)+" <app-id>.")
+	}
+
+	engine := socketEngine(path)
+	report.SystemdUnits = systemdSocketUnits(engine)
+
+	info, err := os.Stat(report.ResolvedPath)
+	switch {
+	case err == nil:
+		report.Exists = true
+		report.IsSocket = info.Mode().Type()&(fs.ModeSocket|fs.ModeNamedPipe) != 0
+		report.inspectOwnership(info)
+	case errors.Is(err, fs.ErrPermission):
+		report.add("parent-not-traversable", "error",
+			fmt.Sprintf("A parent directory of %s is not accessible to the current user.", report.ResolvedPath),
+			"Check the permissions of "+filepath.Dir(report.ResolvedPath)+".")
+	default:
+		report.add("missing", "error", fmt.Sprintf("%s does not exist.", report.ResolvedPath), startRemediation(engine, path, report.SystemdUnits))
+	}
+	if report.Exists && !report.IsSocket && runtime.GOOS != "windows" {
+		report.add("not-a-socket", "error", fmt.Sprintf("%s exists but is not a socket.", report.ResolvedPath),
+			"Remove the stale file and restart the engine service.")
+	}
+
+	if report.Exists {
+		report.probeDial()
+	}
+	return report
+}
+
+func (d *SocketDiagnostics) add(code, severity, message, remediation string) {
+	d.Findings = append(d.Findings, SocketFinding{Code: code, Severity: severity, Message: message, Remediation: remediation})
+}
+
+// inspectOwnership fills owner/group/mode and decides whether the current user can reach the socket through its
+// owner, group, or other bits. Windows pipes carry no unix ownership, so only the mode string is left empty.
+func (d *SocketDiagnostics) inspectOwnership(info fs.FileInfo) {
+	uid, gid, ok := fileOwnership(info)
+	if !ok {
+		return
+	}
+	d.Mode = info.Mode().String()
+	d.Owner = lookupUserName(uid)
+	d.Group = lookupGroupName(gid)
+	current, err := user.Current()
+	if err != nil {
+		return
+	}
+	groups, _ := current.GroupIds()
+	d.InGroup = slices.Contains(groups, strconv.FormatUint(uint64(gid), 10)) || current.Gid == strconv.FormatUint(uint64(gid), 10)
+	perm := info.Mode().Perm()
+	isOwner := current.Uid == strconv.FormatUint(uint64(uid), 10)
+	switch {
+	case current.Uid == "0", isOwner && perm&0o600 == 0o600, d.InGroup && perm&0o060 == 0o060, perm&0o006 == 0o006:
+		d.add("permissions", "ok", fmt.Sprintf("%s is %s %s:%s and accessible to %s.", d.ResolvedPath, d.Mode, d.Owner, d.Group, current.Username), "")
+	case !d.InGroup && perm&0o060 == 0o060:
+		d.add("not-in-group", "error",
+			fmt.Sprintf("%s is owned by group %s and %s is not a member.", d.ResolvedPath, d.Group, current.Username),
+			fmt.Sprintf("sudo usermod -aG %s %s, then log out and back in (or run newgrp %s).", d.Group, current.Username, d.Group))
+	default:
+		d.add("permission-denied", "error",
+			fmt.Sprintf("%s is %s %s:%s — %s has no read/write access.", d.ResolvedPath, d.Mode, d.Owner, d.Group, current.Username),
+			"Use the rootless socket ($XDG_RUNTIME_DIR/podman/podman.sock) or adjust the socket unit's SocketMode/SocketGroup.")
+	}
+}
+
+// probeDial attempts the same dial the proxy performs, so the report ends with the real transport verdict.
+func (d *SocketDiagnostics) probeDial() {
+	ctx, cancel := context.WithTimeout(context.Background(), socketDiagnosticDialTimeout)
+	defer cancel()
+	conn, err := dialLocalTransport(ctx, d.ResolvedPath)
+	if err == nil {
+		_ = conn.Close()
+		d.Reachable = true
+		d.add("reachable", "ok", fmt.Sprintf("%s accepts connections.", d.ResolvedPath), "")
+		return
+	}
+	d.DialError = err.Error()
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		d.add("refused", "error", fmt.Sprintf("%s exists but nothing is listening (stale socket).", d.ResolvedPath),
+			startRemediation(socketEngine(d.Path), d.Path, d.SystemdUnits))
+	case errors.Is(err, fs.ErrPermission):
+		// Ownership findings above already explain the denial; keep the raw error for the report.
+	default:
+		d.add("dial-failed", "error", "Connecting failed: "+err.Error(), "")
+	}
+}
+
+// socketEngine guesses the engine from the socket path ("podman" | "docker" | "").
+func socketEngine(path string) string {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.Contains(lower, "podman"):
+		return "podman"
+	case strings.Contains(lower, "docker"):
+		return "docker"
+	default:
+		return ""
+	}
+}
+
+// systemdSocketUnits lists the installed <engine>.socket unit files (system + user scopes) — presence only, the
+// unit state needs systemctl, which the remediation text points the user at.
+func systemdSocketUnits(engine string) []string {
+	units := []string{}
+	if runtime.GOOS != "linux" || engine == "" {
+		return units
+	}
+	unit := engine + ".socket"
+	dirs := []string{"/etc/systemd/system", "/usr/lib/systemd/system", "/lib/systemd/system"}
+	userDirs := []string{"/etc/systemd/user", "/usr/lib/systemd/user"}
+	if home, err := os.UserHomeDir(); err == nil {
+		userDirs = append(userDirs, filepath.Join(home, ".config", "systemd", "user"))
+	}
+	for _, dir := range dirs {
+		if fileExists(filepath.Join(dir, unit)) {
+			units = append(units, "system:"+unit)
+			break
+		}
+	}
+	for _, dir := range userDirs {
+		if fileExists(filepath.Join(dir, unit)) {
+			units = append(units, "user:"+unit)
+			break
+		}
+	}
+	return units
+}
+
+// startRemediation suggests how to bring the socket up: the matching systemd unit when installed, else the
+// engine's own service command.
+func startRemediation(engine, path string, units []string) string {
+	rootless := strings.HasPrefix(path, "/run/user/")
+	for _, unit := range units {
+		scope, name, _ := strings.Cut(unit, ":")
+		if scope == "user" && rootless {
+			return "systemctl --user enable --now " + name
+		}
+		if scope == "system" && !rootless {
+			return "sudo systemctl enable --now " + name
+		}
+	}
+	switch engine {
+	case "podman":
+		return "Start the API service: podman system service --time=0 unix://" + path
+	case "docker":
+		return "Start the Docker daemon (Docker Desktop, or sudo systemctl start docker)."
+	default:
+		return "Start the engine service that owns this socket."
+	}
+}
+
+func lookupUserName(uid uint32) string {
+	id := strconv.FormatUint(uint64(uid), 10)
+	if u, err := user.LookupId(id); err == nil {
+		return u.Username
+	}
+	return id
+}
+
+func lookupGroupName(gid uint32) string {
+	id := strconv.FormatUint(uint64(gid), 10)
+	if g, err := user.LookupGroupId(id); err == nil {
+		return g.Name
+	}
+	return id
+}
@@ -0,0 +1,56 @@
+//go:build !windows
+
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func hasFinding(report SocketDiagnostics, code string) bool {
+	for _, finding := range report.Findings {
+		if finding.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// A live unix listener owned by the test user is reported present, a socket, accessible, and reachable; a missing
+// path and a plain file get the matching error findings (each with a remediation).
+func TestDiagnoseSocket(t *testing.T) {
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "engine.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	live := diagnoseSocket("unix://" + socket)
+	if live.Path != socket || !live.Exists || !live.IsSocket || !live.Reachable {
+		t.Fatalf("live socket report: %+v", live)
+	}
+	if live.Mode == "" || live.Owner == "" || !hasFinding(live, "permissions") || !hasFinding(live, "reachable") {
+		t.Fatalf("live socket findings: %+v", live.Findings)
+	}
+
+	missing := diagnoseSocket("unix://" + filepath.Join(dir, "podman", "podman.sock"))
+	if missing.Exists || !hasFinding(missing, "missing") || missing.Findings[len(missing.Findings)-1].Remediation == "" {
+		t.Fatalf("missing socket report: %+v", missing)
+	}
+
+	plain := filepath.Join(dir, "docker.sock")
+	if err := os.WriteFile(plain, nil, 0o600); err != nil {
+		t.Fatalf("write plain file: %v", err)
+	}
+	if report := diagnoseSocket(plain); !report.Exists || report.IsSocket || !hasFinding(report, "not-a-socket") {
+		t.Fatalf("plain file report: %+v", report)
+	}
+
+	if report := diagnoseSocket(""); !hasFinding(report, "no-path") {
+		t.Fatalf("empty uri report: %+v", report)
+	}
+}
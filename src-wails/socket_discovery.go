@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Local engine auto-discovery — probes the well-known socket locations so the connection picker can offer the
+// engines that actually answer instead of making the user type a URI. Every candidate goes through the same
+// scheme strip + Flatpak remap as resolveSocketPath, and the probes run in parallel with a short per-probe bound.
+
+const socketProbeTimeout = 1200 * time.Millisecond
+
+// DiscoveredSocket is one candidate that exists on disk (or is a Windows pipe). Engine is "podman" | "docker" | ""
+// (unknown / unreachable); Version and APIVersion come from GET /version when the /_ping succeeded.
+type DiscoveredSocket struct {
+	URI          string `json:"uri"`
+	Path         string `json:"path"`
+	ResolvedPath string `json:"resolvedPath"`
+	Source       string `json:"source"`
+	Reachable    bool   `json:"reachable"`
+	Engine       string `json:"engine"`
+	Version      string `json:"version,omitempty"`
+	APIVersion   string `json:"apiVersion,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+type socketCandidate struct {
+	uri    string
+	source string
+}
+
+// DiscoverSockets probes every well-known local engine socket and reports the ones present, in candidate order,
+// with whether they answer /_ping and their engine + version. Infallible: a dead socket is reported, not raised.
+func (s *PlatformService) DiscoverSockets() []DiscoveredSocket {
+	return discoverSockets(socketCandidates())
+}
+
+// socketCandidates lists the well-known locations: the env overrides first (what the CLIs would use), then the
+// rootless/rootful Podman and Docker sockets, Docker Desktop, and the colima/lima VM sockets.
+func socketCandidates() []socketCandidate {
+	candidates := []socketCandidate{}
+	for _, name := range []string{"CONTAINER_HOST", "DOCKER_HOST"} {
+		if value := os.Getenv(name); strings.HasPrefix(value, "unix://") || strings.HasPrefix(value, "npipe://") {
+			candidates = append(candidates, socketCandidate{uri: value, source: name})
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return append(candidates,
+			socketCandidate{uri: "npipe:////./pipe/docker_engine", source: "docker-desktop"},
+			socketCandidate{uri: "npipe:////./pipe/podman-machine-default", source: "podman-machine"},
+		)
+	}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		candidates = append(candidates,
+			socketCandidate{uri: "unix://" + filepath.Join(runtimeDir, "podman", "podman.sock"), source: "podman-rootless"},
+			socketCandidate{uri: "unix://" + filepath.Join(runtimeDir, "docker.sock"), source: "docker-rootless"},
+		)
+	}
+	candidates = append(candidates,
+		socketCandidate{uri: "unix:///run/podman/podman.sock", source: "podman-rootful"},
+		socketCandidate{uri: "unix:///var/run/docker.sock", source: "docker"},
+	)
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return candidates
+	}
+	candidates = append(candidates,
+		socketCandidate{uri: "unix://" + filepath.Join(home, ".docker", "run", "docker.sock"), source: "docker-desktop"},
+		socketCandidate{uri: "unix://" + filepath.Join(home, ".docker", "desktop", "docker.sock"), source: "docker-desktop"},
+	)
+	for _, pattern := range []string{
+		filepath.Join(home, ".colima", "*", "docker.sock"),
+		filepath.Join(home, ".config", "colima", "*", "docker.sock"),
+	} {
+		matches, _ := filepath.Glob(pattern)
+		for _, match := range matches {
+			candidates = append(candidates, socketCandidate{uri: "unix://" + match, source: "colima:" + filepath.Base(filepath.Dir(match))})
+		}
+	}
+	for _, name := range []string{"docker.sock", "podman.sock"} {
+		matches, _ := filepath.Glob(filepath.Join(home, ".lima", "*", "sock", name))
+		for _, match := range matches {
+			instance := filepath.Base(filepath.Dir(filepath.Dir(match)))
+			candidates = append(candidates, socketCandidate{uri: "unix://" + match, source: "lima:" + instance})
+		}
+	}
+	return candidates
+}
+
+// discoverSockets dedupes the candidates by resolved path, drops the ones not on disk, and probes the rest in
+// parallel. The result keeps candidate order so the env overrides stay on top.
+func discoverSockets(candidates []socketCandidate) []DiscoveredSocket {
+	seen := map[string]bool{}
+	found := []DiscoveredSocket{}
+	for _, candidate := range candidates {
+		path := stripSocketScheme(candidate.uri)
+		resolved := flatpakRemap(path)
+		if path == "" || seen[resolved] {
+			continue
+		}
+		seen[resolved] = true
+		if !strings.HasPrefix(path, "//./pipe/") && !fileExists(resolved) {
+			continue
+		}
+		found = append(found, DiscoveredSocket{URI: candidate.uri, Path: path, ResolvedPath: resolved, Source: candidate.source})
+	}
+	var probes sync.WaitGroup
+	for index := range found {
+		probes.Go(func() { probeEngineSocket(&found[index]) })
+	}
+	probes.Wait()
+	return found
+}
+
+// probeEngineSocket GETs /_ping (liveness + engine type from the Libpod-* headers Podman adds), then /version for
+// the version strings. One throwaway client per probe — nothing is pooled for a socket the user may not pick.
+func probeEngineSocket(found *DiscoveredSocket) {
+	ctx, cancel := context.WithTimeout(context.Background(), socketProbeTimeout)
+	defer cancel()
+	client := &http.Client{Transport: &http.Transport{
+		DisableKeepAlives: true,
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialLocalTransport(ctx, found.ResolvedPath)
+		},
+	}}
+	ping, err := probeGet(ctx, client, "/_ping")
+	if err != nil {
+		found.Error = err.Error()
+		return
+	}
+	_ = ping.Body.Close()
+	if ping.StatusCode != http.StatusOK {
+		found.Error = fmt.Sprintf("/_ping answered %d", ping.StatusCode)
+		return
+	}
+	found.Reachable = true
+	found.Engine = "docker"
+	if ping.Header.Get("Libpod-Api-Version") != "" {
+		found.Engine = "podman"
+	}
+	found.APIVersion = ping.Header.Get("Api-Version")
+
+	version, err := probeGet(ctx, client, "/version")
+	if err != nil {
+		return
+	}
+	defer func() { _ = version.Body.Close() }()
+	var body struct {
+		Version    string `json:"Version"`
+		APIVersion string `json:"ApiVersion"`
+	}
+	if json.NewDecoder(io.LimitReader(version.Body, 1<<20)).Decode(&body) == nil {
+		found.Version = body.Version
+		if body.APIVersion != "" {
+			found.APIVersion = body.APIVersion
+		}
+	}
+}
+
+func probeGet(ctx context.Context, client *http.Client, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://d"+path, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Container Desktop")
+	return client.Do(req)
+}
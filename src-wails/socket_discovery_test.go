@@ -0,0 +1,85 @@
+//go:build !windows
+
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// serveFakeEngine answers /_ping + /version on a unix socket; podman=true adds the Libpod-Api-Version header the
+// real Podman service sends, which is how discovery tells the engines apart.
+func serveFakeEngine(t *testing.T, socket string, podman bool, version string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(socket), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/_ping", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Api-Version", "1.41")
+		if podman {
+			w.Header().Set("Libpod-Api-Version", version)
+		}
+		_, _ = io.WriteString(w, "OK")
+	})
+	mux.HandleFunc("/version", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, `{"Version":"`+version+`","ApiVersion":"1.43"}`)
+	})
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+}
+
+// Discovery finds the rootless Podman socket under XDG_RUNTIME_DIR and a DOCKER_HOST override, identifies each
+// engine from /_ping, reads the version, dedupes a repeated path, and reports a dead socket as unreachable.
+func TestDiscoverSockets(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	podmanSocket := filepath.Join(dir, "podman", "podman.sock")
+	serveFakeEngine(t, podmanSocket, true, "5.2.0")
+	dockerSocket := filepath.Join(dir, "docker-host.sock")
+	serveFakeEngine(t, dockerSocket, false, "27.1.1")
+	t.Setenv("DOCKER_HOST", "unix://"+dockerSocket)
+	t.Setenv("CONTAINER_HOST", "unix://"+podmanSocket) // same path as the rootless candidate → listed once
+
+	deadSocket := filepath.Join(dir, "dead.sock")
+	if err := os.WriteFile(deadSocket, nil, 0o600); err != nil {
+		t.Fatalf("write dead socket: %v", err)
+	}
+
+	found := discoverSockets(append(socketCandidates(),
+		socketCandidate{uri: "unix://" + deadSocket, source: "test"},
+		socketCandidate{uri: "unix://" + filepath.Join(dir, "absent.sock"), source: "test"},
+	))
+	byPath := map[string]DiscoveredSocket{}
+	for _, socket := range found {
+		if _, dup := byPath[socket.Path]; dup {
+			t.Fatalf("duplicate entry for %s", socket.Path)
+		}
+		byPath[socket.Path] = socket
+	}
+
+	podman := byPath[podmanSocket]
+	if !podman.Reachable || podman.Engine != "podman" || podman.Version != "5.2.0" || podman.Source != "CONTAINER_HOST" {
+		t.Errorf("podman socket: %+v", podman)
+	}
+	docker := byPath[dockerSocket]
+	if !docker.Reachable || docker.Engine != "docker" || docker.Version != "27.1.1" || docker.APIVersion != "1.43" {
+		t.Errorf("docker socket: %+v", docker)
+	}
+	if dead, ok := byPath[deadSocket]; !ok || dead.Reachable || dead.Error == "" {
+		t.Errorf("dead socket: %+v (present=%v)", dead, ok)
+	}
+	if _, ok := byPath[filepath.Join(dir, "absent.sock")]; ok {
+		t.Error("absent socket must not be reported")
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachFromTerminal starts the child in its own session, without a controlling terminal. When the app was
+// launched from a shell, ssh would otherwise prompt for a password on that tty and block unseen; detached, it
+// has to report the prompt on stderr (or fail), where the tunnel's promptWatcher sees it.
+func detachFromTerminal(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+}
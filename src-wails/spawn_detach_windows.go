@@ -0,0 +1,8 @@
+//go:build windows
+
+package main
+
+import "os/exec"
+
+// detachFromTerminal is a no-op on Windows: configureHiddenWindow already gives the child no console.
+func detachFromTerminal(_ *exec.Cmd) {}
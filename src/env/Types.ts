@@ -216,6 +216,12 @@ export interface ApiConnection {
   // dial-stdio` on a Windows named pipe; Podman-machine: a nested OpenSSH hop into the VM + its local
   // dial-stdio). The SSH transport just runs whatever command the dialect resolved (see resolveDialStdioBridge).
   dialStdioCommand?: string[];
+  // Wails shell only: keep this many dial-stdio children pre-spawned per bridge (each retired after
+  // maxLifetimeMs), so a request after idle does not pay the SSH handshake.
+  dialStdioPool?: { size: number; maxLifetimeMs?: number };
+  // Wails shell only: "builtin" reaches the remote through the in-process Go SSH client (the "ssh" bridge kind)
+  // instead of the system `ssh` binary — for hosts without OpenSSH, and for real auth/host-key errors.
+  sshClient?: "system" | "builtin";
 }
 
 // How to bridge an engine whose API can't be `ssh -NL` forwarded: a stable relay id + the command to run.
@@ -1317,6 +1323,13 @@ export interface CommandExecutionResult {
   stdout?: string;
   stderr?: string;
   command?: string;
+  // Wails: timeoutMs ran out; stdout/stderr hold the output captured before the kill.
+  timedOut?: boolean;
+  // Wails: the stream went past its maxStdoutBytes / maxStderrBytes cap.
+  stdoutTruncated?: boolean;
+  stderrTruncated?: boolean;
+  // Wails: "base64" when requested with stdoutEncoding.
+  stdoutEncoding?: string;
 }
 
 export interface Wrapper {
@@ -53,6 +53,9 @@ const COMMAND_TO_METHOD: Record<string, string> = {
   is_flatpak: "main.PlatformService.IsFlatpak",
   get_user_data_path: "main.PlatformService.GetUserDataPath",
   get_ssh_config: "main.PlatformService.GetSSHConfig",
+  diagnose_socket: "main.PlatformService.DiagnoseSocket",
+  discover_sockets: "main.PlatformService.DiscoverSockets",
+  get_engine_connections: "main.PlatformService.GetEngineConnections",
   // FsService (built).
   fs_read_text_file: "main.FsService.ReadTextFile",
   fs_write_text_file: "main.FsService.WriteTextFile",
@@ -68,8 +71,29 @@ const COMMAND_TO_METHOD: Record<string, string> = {
   proxy_stream_destroy: "main.ProxyService.StreamDestroy",
   proxy_test_connectivity: "main.ProxyService.TestConnectivity",
   proxy_bridge_stop: "main.BridgeService.Stop",
+  proxy_bridge_stderr: "main.BridgeService.Stderr",
+  proxy_bridge_list: "main.BridgeService.List",
+  proxy_bridge_restart: "main.BridgeService.Restart",
+  proxy_bridge_forward_create: "main.BridgeService.CreatePortForward",
+  proxy_bridge_forward_list: "main.BridgeService.ListPortForwards",
+  proxy_bridge_forward_stop: "main.BridgeService.StopPortForward",
+  proxy_bridge_publish: "main.BridgeService.PublishConnection",
+  proxy_bridge_unpublish: "main.BridgeService.UnpublishConnection",
+  proxy_bridge_published_list: "main.BridgeService.ListPublishedConnections",
   process_spawn: "main.ProcessService.Spawn",
   process_kill: "main.ProcessService.Kill",
+  process_write: "main.ProcessService.Write",
+  process_resize: "main.ProcessService.Resize",
+  process_stdin_write: "main.ProcessService.WriteStdin",
+  process_stdin_close: "main.ProcessService.CloseStdin",
+  process_list: "main.ProcessService.List",
+  process_attach: "main.ProcessService.Attach",
+  process_supervise: "main.ProcessService.Supervise",
+  process_service_stop: "main.ProcessService.StopService",
+  process_service_list: "main.ProcessService.ListServices",
+  process_jobs_list: "main.ProcessService.ListJobs",
+  process_job_get: "main.ProcessService.GetJob",
+  process_jobs_prune: "main.ProcessService.PruneJobs",
   // KeychainService (Phase 3) / ShellService + TrayService (Phase 4).
   keychain_status: "main.KeychainService.Status",
   keychain_has: "main.KeychainService.Has",
@@ -40,6 +40,13 @@ export async function exec_launcher_async(
   if (opts?.timeout !== undefined) {
     payload.timeoutMs = opts.timeout;
   }
+  // Per-stream byte caps (the Go side truncates and flags stdoutTruncated/stderrTruncated), binary-safe stdout
+  // ("base64") and stdout written straight to a file (e.g. `podman save`).
+  for (const key of ["maxStdoutBytes", "maxStderrBytes", "stdoutEncoding", "stdoutFile"]) {
+    if (opts?.[key] !== undefined) {
+      payload[key] = opts[key];
+    }
+  }
   // Secret-bearing stdin (registry `login --password-stdin`, `cat > ca.crt`) — piped to the child by the Go
   // side (exec_service.go), never placed in argv or logged.
   if (opts?.input !== undefined) {
@@ -88,6 +95,27 @@ export async function exec_streaming(
   };
 }
 
+// Re-attach to a process spawned before a renderer reload (find it with process_list): its buffered output is
+// replayed first, then it streams live. Returns a StreamHandle like exec_streaming.
+export async function exec_attach(deps: CommandDeps, processId: string): Promise<StreamHandle> {
+  const emitter = new EventEmitter();
+  const channel = deps.newChannel();
+  channel.onmessage = (message) => applyProcessEvent(emitter, message);
+  await deps.invoke("process_attach", { payload: { processId }, channel });
+  return {
+    on: (event, listener) => {
+      emitter.on(event, listener);
+    },
+    off: (event, listener) => {
+      emitter.off(event, listener);
+    },
+    dispose: () => emitter.removeAllListeners(),
+    kill: (signal) => {
+      void killProcess(deps, { __processId: processId }, signal);
+    },
+  };
+}
+
 // ExecuteAsBackgroundService — spawn a long-lived service, poll opts.checkStatus until ready. Returns { on }.
 export async function exec_service(
   deps: CommandDeps,
@@ -4,13 +4,31 @@ import { getEngineProxyEnv } from "@/platform/proxy-env-policy";
 // The Go-side process event (src-wails/process.go ProcessEvent).
 export interface ProcessEventMessage {
   processId?: string;
-  type: "data" | "exit" | "close" | "error";
-  from?: "stdout" | "stderr";
+  type: "data" | "exit" | "close" | "error" | "state" | "stats" | "timeout";
+  from?: "stdout" | "stderr" | "pty";
   data?: string;
+  // Set for a PTY child's output: data is base64 of raw terminal bytes.
+  binary?: boolean;
+  // Bytes of this stream dropped before this data because the renderer fell behind (Go-side batching).
+  overflow?: number;
   code?: number | null;
   signal?: string;
+  // exit: Kill's grace period ran out and the process group was SIGKILLed.
+  escalated?: boolean;
+  // timeout / exit: the spawn deadline that ended the process (timeoutMs | idleTimeoutMs).
+  timeout?: "wall" | "idle";
+  // Re-emitted from the process's replay log by process_attach (after a renderer reload).
+  replay?: boolean;
   errorType?: string;
   error?: string;
+  // state: a supervised service's transition (process_supervise).
+  serviceId?: string;
+  state?: string;
+  reason?: string;
+  restarts?: number;
+  // stats: a resource sample of the process tree; exit: the summary (when spawned with statsIntervalMs).
+  stats?: Record<string, number | string>;
+  usage?: Record<string, number>;
 }
 
 export interface ProcessChannel {
@@ -30,14 +48,33 @@ function processSpawnEnv(opts?: any): Record<string, string> | undefined {
   return { ...proxyEnv, ...(opts?.env ?? {}) };
 }
 
+function decodeBase64(data: string): Uint8Array {
+  const binary = atob(data);
+  const bytes = new Uint8Array(binary.length);
+  for (let index = 0; index < binary.length; index++) {
+    bytes[index] = binary.charCodeAt(index);
+  }
+  return bytes;
+}
+
 // Go ProcessEvent -> the emitter events node/exec/commander.ts emits (payload shapes per contract.ts).
 export function applyProcessEvent(emitter: EventEmitter, message: ProcessEventMessage): void {
   switch (message?.type) {
     case "data":
-      emitter.emit("data", { from: message.from, data: message.data ?? "" });
+      emitter.emit("data", {
+        from: message.from,
+        data: message.binary ? decodeBase64(message.data ?? "") : (message.data ?? ""),
+        overflow: message.overflow,
+      });
       break;
     case "exit":
-      emitter.emit("exit", { code: message.code ?? null, signal: message.signal });
+      emitter.emit("exit", {
+        code: message.code ?? null,
+        signal: message.signal,
+        escalated: message.escalated,
+        timeout: message.timeout,
+        usage: message.usage,
+      });
       break;
     case "close":
       emitter.emit("close", { code: message.code ?? null });
@@ -45,19 +82,59 @@ export function applyProcessEvent(emitter: EventEmitter, message: ProcessEventMe
     case "error":
       emitter.emit("error", { type: message.errorType ?? "process.error", error: message.error });
       break;
+    case "stats":
+      emitter.emit("stats", message.stats);
+      break;
+    case "timeout":
+      emitter.emit("timeout", { timeout: message.timeout, reason: message.reason });
+      break;
+    case "state":
+      emitter.emit("state", {
+        serviceId: message.serviceId,
+        processId: message.processId,
+        state: message.state,
+        reason: message.reason,
+        restarts: message.restarts ?? 0,
+      });
+      break;
   }
 }
 
 export function processSpawnPayload(launcher: string, args: string[], opts?: any): Record<string, unknown> {
-  return { launcher, args: args ?? [], cwd: opts?.cwd, env: processSpawnEnv(opts) };
+  return {
+    launcher,
+    args: args ?? [],
+    cwd: opts?.cwd,
+    env: processSpawnEnv(opts),
+    pty: opts?.pty ? true : undefined,
+    cols: opts?.cols,
+    rows: opts?.rows,
+    // A stdin pipe fed by process_stdin_write / process_stdin_close (otherwise the child reads the null device).
+    stdin: opts?.stdin ? true : undefined,
+    statsIntervalMs: opts?.statsIntervalMs,
+    history: opts?.history ? true : undefined,
+    // isolate: start from an empty environment; inheritEnv: the parent variables to keep ("LC_*" is a prefix).
+    isolate: opts?.isolate ? true : undefined,
+    inheritEnv: opts?.inheritEnv,
+    timeoutMs: opts?.timeoutMs ?? opts?.timeout,
+    idleTimeoutMs: opts?.idleTimeoutMs,
+  };
 }
 
 function toSignal(signal: unknown): string | undefined {
   return signal == null ? undefined : String(signal);
 }
 
-async function killRustProcess(deps: CommandDeps, processId: string, signal?: unknown): Promise<void> {
-  await deps.invoke("process_kill", { payload: { processId, signal: toSignal(signal) } }).catch(() => undefined);
+// graceMs: SIGKILL the process group when it is still running that long after `signal`.
+async function killRustProcess(
+  deps: CommandDeps,
+  processId: string,
+  signal?: unknown,
+  graceMs?: number,
+): Promise<void> {
+  await deps
+    .invoke("process_kill", { payload: { processId, signal: toSignal(signal), graceMs } })
+    .catch(() => undefined);
 }
 
 // Mirrors node/exec/commander.ts wrap_process: the { process, child } shape onSpawn/ready hands to callers.
@@ -81,9 +158,9 @@ export function wrap_process(deps: CommandDeps, proc: any, processId: string | u
 }
 
 // Terminate a Go-owned process from a processId-stamped child, or delegate to a child kill function.
-export async function killProcess(deps: CommandDeps, target: any, signal?: unknown): Promise<void> {
+export async function killProcess(deps: CommandDeps, target: any, signal?: unknown, graceMs?: number): Promise<void> {
   if (target?.__processId) {
-    await killRustProcess(deps, target.__processId, signal);
+    await killRustProcess(deps, target.__processId, signal, graceMs);
     return;
   }
   if (typeof target?.kill === "function") {
@@ -0,0 +1,41 @@
+import { describe, expect, it } from "vitest";
+
+import { buildBridgeSpec } from "./proxy-request";
+
+describe("buildBridgeSpec (wails)", () => {
+  const remote = (connection: Record<string, unknown>) => ({
+    host: "docker.remote",
+    id: "ssh.docker.prod",
+    settings: {
+      controller: { scope: "prod" },
+      api: { connection: { uri: "/tmp/cd-ssh-prod.sock", relay: "/run/user/1000/docker.sock", ...connection } },
+    },
+  });
+
+  it("hands a dial-stdio remote to the in-process SSH client when sshClient is builtin", () => {
+    const spec = buildBridgeSpec(
+      remote({ dialStdioCommand: ["docker", "system", "dial-stdio"], sshClient: "builtin" }),
+      "Windows_NT",
+    );
+    expect(spec).toEqual({
+      kind: "ssh",
+      key: "/run/user/1000/docker.sock",
+      localAddress: "/tmp/cd-ssh-prod.sock",
+      launcher: "",
+      argv: [],
+      ssh: { configHost: "prod", command: "docker system dial-stdio" },
+    });
+  });
+
+  it("forwards the remote socket in-process when there is no dial-stdio command", () => {
+    const spec = buildBridgeSpec(remote({ relay: "unix:///run/podman.sock", sshClient: "builtin" }), "Linux");
+    expect(spec?.kind).toBe("ssh");
+    expect(spec?.ssh).toEqual({ configHost: "prod", remoteSocket: "/run/podman.sock" });
+  });
+
+  it("keeps the system ssh binary by default", () => {
+    const stdio = buildBridgeSpec(remote({ dialStdioCommand: ["docker", "system", "dial-stdio"] }), "Linux");
+    expect(stdio?.kind).toBe("stdio");
+    expect(buildBridgeSpec(remote({ sshClient: "system" }), "Linux")?.kind).toBe("tunnel");
+  });
+});
@@ -5,7 +5,7 @@ import {
   shapeBufferedResponse,
 } from "@/container-client/commandProxyProtocol";
 import { getProxyRequestRoute } from "@/container-client/proxy-route";
-import { buildSSHArgs, buildSSHTunnelArgs } from "@/container-client/ssh-args";
+import { buildSSHArgs, buildSSHTunnelArgs, SSH_CONNECT_TIMEOUT_SECONDS } from "@/container-client/ssh-args";
 import { buildWSLDialStdioArgs } from "@/container-client/wsl-args";
 import { createEmitterStream } from "@/utils/streamEmitter";
 
@@ -25,22 +25,44 @@ export interface ProxyRequestDeps {
 }
 
 // A remote-connection bridge the Go proxy must bring up (and dial the LOCAL end of) before proxying: an SSH
-// dial-stdio bridge / `ssh -NL` tunnel, or a WSL dial-stdio bridge. Built HERE from the shared arg builders
-// (the source of truth for the argv) so Go stays engine-agnostic — it only spawns `launcher argv` and shuttles
-// bytes between `localAddress` and that process's stdio. `undefined` for a direct local dial.
+// dial-stdio bridge / `ssh -NL` tunnel (or the same served by Go's in-process SSH client, kind "ssh"), or a WSL
+// dial-stdio bridge. Built HERE from the shared arg builders (the source of truth for the argv) so Go stays
+// engine-agnostic — it only spawns `launcher argv` and shuttles bytes between `localAddress` and that process's
+// stdio. `undefined` for a direct local dial.
 export interface BridgeSpec {
-  kind: "stdio" | "tunnel";
+  kind: "stdio" | "tunnel" | "ssh";
   key: string;
   localAddress: string;
   launcher: string;
   argv: string[];
+  // kind "ssh" only: the in-process Go SSH client's target (src-wails/bridge_ssh.go sshBridgeSpec). `command` set ⇒
+  // dial-stdio per connection; else each connection is forwarded to `remoteSocket`.
+  ssh?: SSHBridgeSpec;
+  // kind "stdio" only: pre-spawned dial-stdio children (src-wails/bridge_stdio_pool.go stdioPoolSpec).
+  pool?: { size: number; maxLifetimeMs?: number };
+  // kind "tunnel" only: how long Go waits for the engine's /_ping through the forward (default 5 s).
+  readyTimeoutMs?: number;
+}
+
+export interface SSHBridgeSpec {
+  host?: string;
+  port?: number;
+  user?: string;
+  identityFile?: string;
+  configHost?: string;
+  knownHostsFile?: string;
+  forwardAgent?: boolean;
+  keepaliveMs?: number;
+  command?: string;
+  remoteSocket?: string;
 }
 
 // Classify the connection and, for an SSH/WSL remote, produce the bridge spec. SSH: every scope comes from
 // ~/.ssh/config so ConfigHost is the alias — buildSSHArgs drops -i/-p and targets it, so no credential/home
 // resolution reaches Go; dialStdioCommand present ⇒ a per-connection `ssh <alias> -- <cmd>` stdio bridge
-// (Docker/Podman remotes), absent ⇒ a plain `ssh -NL` unix-socket forward. WSL: a `wsl.exe … system dial-stdio`
-// stdio bridge over a named pipe, keyed by connection id.
+// (Docker/Podman remotes), absent ⇒ a plain `ssh -NL` unix-socket forward. sshClient "builtin" hands the same
+// alias + command (or remote socket) to Go's in-process SSH client instead (kind "ssh", no ssh binary).
+// WSL: a `wsl.exe … system dial-stdio` stdio bridge over a named pipe, keyed by connection id.
 export function buildBridgeSpec(connection: any, osType?: string): BridgeSpec | undefined {
   const route = getProxyRequestRoute(connection?.host);
   const api = connection?.settings?.api?.connection ?? {};
@@ -71,8 +93,16 @@ export function buildBridgeSpec(connection: any, osType?: string): BridgeSpec |
       "Remote engine socket could not be determined — is the container engine installed and running on the remote host (and reachable on a non-interactive SSH PATH)?",
     );
   }
+  if (api.sshClient === "builtin") {
+    // Like `ssh host -- cmd…`, the remote command is the argv joined by spaces.
+    const ssh: SSHBridgeSpec = dialStdioCommand?.length
+      ? { configHost: scope, command: dialStdioCommand.join(" ") }
+      : { configHost: scope, remoteSocket: `${remoteAddress}`.replace("unix://", "") };
+    return { kind: "ssh", key, localAddress, launcher: "", argv: [], ssh };
+  }
   if (dialStdioCommand && dialStdioCommand.length > 0) {
-    return { kind: "stdio", key, localAddress, launcher, argv: buildSSHArgs(credentials, dialStdioCommand) };
+    const pool = api.dialStdioPool;
+    return { kind: "stdio", key, localAddress, launcher, argv: buildSSHArgs(credentials, dialStdioCommand), pool };
   }
   if (osType === "Windows_NT") {
     throw new Error(
@@ -85,6 +115,8 @@ export function buildBridgeSpec(connection: any, osType?: string): BridgeSpec |
     localAddress,
     launcher,
     argv: buildSSHTunnelArgs(credentials, localAddress, remoteAddress),
+    // At least ssh's own ConnectTimeout, so a slow handshake is reported by ssh rather than cut short here.
+    readyTimeoutMs: (SSH_CONNECT_TIMEOUT_SECONDS + 5) * 1000,
   };
 }
 